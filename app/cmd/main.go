@@ -1,6 +1,7 @@
 package main
 
 import (
+	"WBL0/app/internal/auth"
 	"WBL0/app/internal/cache"
 	"WBL0/app/internal/delivery"
 	"WBL0/app/internal/item"
@@ -15,7 +16,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/julienschmidt/httprouter"
 	"golang.org/x/exp/slog"
 	"net/http"
@@ -26,28 +27,74 @@ import (
 )
 
 func main() {
-	log := logger.GetLogger()
-	log.Info("logger initialized")
-
 	configPath := flag.String("config-path", "config.yml", "path for application configuration file")
+	uiMode := flag.String("ui-mode", "", "open-browser, serve-ui or api-only; overrides http.ui_mode")
+	flag.Parse()
+
 	cfg := config.GetConfig(*configPath, ".env")
+	if *uiMode != "" {
+		cfg.HTTP.UIMode = *uiMode
+	}
+
+	log := logger.GetLogger()
+	if err := logger.Init(*cfg); err != nil {
+		log.Fatal("cannot initialize logger:", err)
+	}
 	log.Info("loaded config file")
+	log.Info("logger initialized")
 
-	dbConn, err := postgres.ConnectDB(*cfg)
+	dbPool, err := postgres.ConnectDB(*cfg)
 	if err != nil {
-		log.Error("cannot connect to database", err)
+		log.Fatal("cannot connect to database: ", err)
 	}
 	log.Info("connected to database")
 
-	allCache := cache.NewCache()
-	if err = loadAllCache(log, dbConn, allCache); err != nil {
-		log.Error("Failed to preload caches:", err)
+	if flag.Arg(0) == "migrate" {
+		err = runMigrate(log, dbPool, flag.Args()[1:])
+		dbPool.Close()
+		if err != nil {
+			log.Fatal("migration failed: ", err)
+		}
+		return
+	}
+
+	if err = auth.ValidateConfig(*cfg); err != nil {
+		log.Fatal("invalid jwt config: ", err)
+	}
+
+	// The cache is warmed up in the background so /healthz and /readyz answer meanwhile;
+	// NATS messages are only consumed once it is done
+	allCache, err := newCache(*cfg)
+	if err != nil {
+		log.Fatal("cannot create cache: ", err)
 	}
-	log.Info("records from the database are added to the cache")
+	go func() {
+		start := time.Now()
+		loadErr := warmUpCache(log, dbPool, allCache, *cfg)
+		allCache.FinishLoad(loadErr)
+		if loadErr != nil {
+			log.Error("Failed to preload caches:", loadErr)
+			return
+		}
+		log.Infof("cache warm-up finished in %s: %d orders, %d deliveries, %d payments, %d items",
+			time.Since(start).Round(time.Millisecond), allCache.Orders().Len(), allCache.Deliveries().Len(), allCache.Payments().Len(), allCache.Items().Len())
+	}()
 
-	fmt.Println("Cache after loading data:")
-	for key, value := range allCache.Payments {
-		fmt.Printf("Key: %d, Value: %+v\n", key, value)
+	stopSnapshots := make(chan struct{})
+	if cfg.Cache.SnapshotFile != "" && cfg.Cache.SnapshotInterval > 0 {
+		go func() {
+			<-allCache.Loaded()
+			ticker := time.NewTicker(time.Duration(cfg.Cache.SnapshotInterval) * time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					writeSnapshot(log, allCache, cfg.Cache.SnapshotFile)
+				case <-stopSnapshots:
+					return
+				}
+			}
+		}()
 	}
 
 	natsConn, err := nats.ConnectNATS(*cfg)
@@ -67,7 +114,7 @@ func main() {
 	signal.Notify(quit, signals...)
 
 	go func() {
-		if err = srv.Run(dbConn, natsConn); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		if err = srv.Run(dbPool, natsConn); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Error("cannot run the server", err)
 		}
 	}()
@@ -76,15 +123,7 @@ func main() {
 	<-quit
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer func() {
-		dbCloseCtx, dbCloseCancel := context.WithTimeout(
-			context.Background(),
-			time.Duration(cfg.PostgreSQL.ShutdownTimeout)*time.Second,
-		)
-		defer dbCloseCancel()
-		err = dbConn.Close(dbCloseCtx)
-		if err != nil {
-			log.Error("failed to close database connection:", err)
-		}
+		dbPool.Close()
 		log.Info("closed database connection")
 
 		natsConn.Close()
@@ -99,23 +138,103 @@ func main() {
 		log.Error("server shutdown failed:", err)
 	}
 	log.Info("server has been shutted down")
+
+	close(stopSnapshots)
+	if cfg.Cache.SnapshotFile != "" {
+		writeSnapshot(log, allCache, cfg.Cache.SnapshotFile)
+	}
 }
 
-func loadAllCache(log logger.Logger, dbConn *pgx.Conn, cache *cache.Cache) error {
-	if err := delivery.CacheForDelivery(dbConn, cache); err != nil {
+func newCache(cfg config.Config) (*cache.Cache, error) {
+	switch cfg.Cache.Mode {
+	case cache.ModeFull:
+		return cache.NewCache(), nil
+	case cache.ModeBounded:
+		return cache.NewBoundedCache(cache.Limits{
+			MaxEntries: cfg.Cache.MaxEntries,
+			MaxBytes:   cfg.Cache.MaxBytes,
+			TTL:        time.Duration(cfg.Cache.TTL) * time.Second,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown cache mode %q", cfg.Cache.Mode)
+	}
+}
+
+// warmUpCache loads the cache snapshot and the orders stored after it, and falls back
+// to loading from the DB alone when there is no usable snapshot.
+func warmUpCache(log logger.Logger, dbPool *pgxpool.Pool, cache *cache.Cache, cfg config.Config) error {
+	if cfg.Cache.SnapshotFile == "" {
+		return loadAllCache(log, dbPool, cache, cfg.Cache.WarmupOrders)
+	}
+
+	info, err := cache.LoadSnapshot(cfg.Cache.SnapshotFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			log.Info("no cache snapshot found, loading the cache from the database")
+		} else {
+			log.Warn("cannot load cache snapshot, loading the cache from the database: ", err)
+		}
+		return loadAllCache(log, dbPool, cache, cfg.Cache.WarmupOrders)
+	}
+	log.Infof("loaded cache snapshot of %s with %d orders", info.CreatedAt.Format(time.RFC3339), info.Orders)
+
+	// Delivery IDs are taken when an order is inserted rather than when it is committed, so an order
+	// committed after the snapshot can sit below its watermark; the margin below it is read again.
+	// A bounded cache catches up on no more orders than it warms up with.
+	limit := 0
+	if cache.Bounded() {
+		limit = cfg.Cache.WarmupOrders
+	}
+	loaded, err := order.CacheForOrdersAfter(dbPool, cache, info.Watermark-cfg.Cache.CatchUpMargin, limit)
+	if err != nil {
+		log.Error("Failed to load orders stored after the cache snapshot:", err)
+		return err
+	}
+	log.Infof("loaded %d orders stored after delivery %d of the cache snapshot, with a margin of %d", loaded, info.Watermark, cfg.Cache.CatchUpMargin)
+	return nil
+}
+
+// writeSnapshot writes the cache snapshot unless the warm-up has not finished or failed,
+// since a snapshot of a partly loaded cache would hide the rest of the rows from the next start.
+func writeSnapshot(log logger.Logger, cache *cache.Cache, path string) {
+	if done, err := cache.LoadState(); !done || err != nil {
+		log.Warn("cache is not loaded, skipping cache snapshot")
+		return
+	}
+
+	start := time.Now()
+	info, err := cache.WriteSnapshot(path)
+	if err != nil {
+		log.Error("cannot write cache snapshot:", err)
+		return
+	}
+	log.Infof("wrote cache snapshot of %d orders up to delivery %d in %s", info.Orders, info.Watermark, time.Since(start).Round(time.Millisecond))
+}
+
+// loadAllCache loads every row into a full cache, and only the most recent orders into a bounded one.
+func loadAllCache(log logger.Logger, dbPool *pgxpool.Pool, cache *cache.Cache, warmupOrders int) error {
+	if cache.Bounded() {
+		if err := order.CacheForRecentOrders(dbPool, cache, warmupOrders); err != nil {
+			log.Error("Failed to load recent orders into cache:", err)
+			return err
+		}
+		return nil
+	}
+
+	if err := delivery.CacheForDelivery(dbPool, cache); err != nil {
 		log.Error("Failed to load delivery data into cache:", err)
 		return err
 	}
-	if err := order.CacheForOrder(dbConn, cache); err != nil {
+	if err := order.CacheForOrder(dbPool, cache); err != nil {
 		log.Error("Failed to load order data into cache:", err)
 		return err
 	}
 
-	if err := item.CacheForItem(dbConn, cache); err != nil {
+	if err := item.CacheForItem(dbPool, cache); err != nil {
 		log.Error("Failed to load item data into cache:", err)
 		return err
 	}
-	if err := payment.CacheForPayment(dbConn, cache); err != nil {
+	if err := payment.CacheForPayment(dbPool, cache); err != nil {
 		log.Error("Failed to load payment data into cache:", err)
 		return err
 	}
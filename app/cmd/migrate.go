@@ -0,0 +1,59 @@
+package main
+
+import (
+	"WBL0/app/pkg/logger"
+	postgres "WBL0/app/pkg/storage"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"strconv"
+)
+
+// runMigrate handles "migrate up", "migrate down [steps]" and "migrate status".
+func runMigrate(log logger.Logger, dbPool *pgxpool.Pool, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up | down [steps] | status")
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := postgres.MigrateUp(ctx, dbPool)
+		for _, m := range applied {
+			log.Infof("applied migration %d_%s", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			log.Info("schema is up to date")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("steps must be a positive number")
+			}
+			steps = n
+		}
+		reverted, err := postgres.MigrateDown(ctx, dbPool, steps)
+		for _, m := range reverted {
+			log.Infof("reverted migration %d_%s", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := postgres.MigrationsStatus(ctx, dbPool)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			if s.AppliedAt == nil {
+				log.Infof("%d_%s: pending", s.Version, s.Name)
+				continue
+			}
+			log.Infof("%d_%s: applied at %s", s.Version, s.Name, s.AppliedAt.Format("2006-01-02 15:04:05"))
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q, use up, down or status", args[0])
+	}
+}
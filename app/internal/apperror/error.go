@@ -16,10 +16,11 @@ var (
 )
 
 type AppError struct {
-	Err              error  `json:"-"`
-	Message          string `json:"message,omitempty"`
-	DeveloperMessage string `json:"developer_message,omitempty"`
-	Code             int    `json:"code,omitempty"`
+	Err              error        `json:"-"`
+	Message          string       `json:"message,omitempty"`
+	DeveloperMessage string       `json:"developer_message,omitempty"`
+	Code             int          `json:"code,omitempty"`
+	Fields           []FieldError `json:"fields,omitempty"`
 }
 
 func NewAppError(code int, developerMessage, message string) *AppError {
@@ -0,0 +1,43 @@
+package apperror
+
+import (
+	"fmt"
+	"strings"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError collects every invalid field of a request instead of stopping at the first one.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Add(field, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+}
+
+// Check records message for field when ok is false.
+func (e *ValidationError) Check(ok bool, field, message string) {
+	if !ok {
+		e.Add(field, message)
+	}
+}
+
+// Err returns nil when no field has failed validation.
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		fields = append(fields, fmt.Sprintf("%s: %s", f.Field, f.Message))
+	}
+	return "validation failed: " + strings.Join(fields, "; ")
+}
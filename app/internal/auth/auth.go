@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"WBL0/app/internal/handler"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	TokenAccess  = "access"
+	TokenRefresh = "refresh"
+)
+
+const (
+	RoleSupport     = "support"
+	RoleIntegration = "integration"
+	RoleAdmin       = "admin"
+)
+
+// rolePermissions: support staff read, integration systems publish orders, admins do everything.
+var rolePermissions = map[string][]handler.Permission{
+	RoleSupport:     {handler.PermissionRead},
+	RoleIntegration: {handler.PermissionPublish},
+	RoleAdmin:       {handler.PermissionRead, handler.PermissionPublish, handler.PermissionAdmin},
+}
+
+var (
+	ErrInvalidCredentials = errors.New("invalid client credentials")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+)
+
+// Claims are carried by both token types; Type keeps a refresh token from being used as an access token.
+type Claims struct {
+	Type  string   `json:"typ"`
+	Roles []string `json:"roles,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func (c *Claims) Allows(permission handler.Permission) bool {
+	for _, role := range c.Roles {
+		for _, p := range rolePermissions[role] {
+			if p == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type CreateTokenDTO struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+type RefreshTokenDTO struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"WBL0/app/internal/apperror"
+	"WBL0/app/internal/handler"
+	"WBL0/app/internal/response"
+	"WBL0/app/pkg/logger"
+	"errors"
+	"net/http"
+)
+
+const (
+	tokenURL   = "/auth/token"
+	refreshURL = "/auth/refresh"
+)
+
+type Handler struct {
+	log         logger.Logger
+	authService Service
+}
+
+func NewHandler(log logger.Logger, authService Service) handler.Hand {
+	return &Handler{
+		log:         log,
+		authService: authService,
+	}
+}
+
+func (h *Handler) Permissions() handler.Permissions {
+	return handler.Permissions{
+		handler.Route(http.MethodPost, tokenURL):   handler.PermissionPublic,
+		handler.Route(http.MethodPost, refreshURL): handler.PermissionPublic,
+	}
+}
+
+func (h *Handler) Register(router handler.Router) {
+	router.HandlerFunc(http.MethodPost, tokenURL, h.CreateToken)
+	router.HandlerFunc(http.MethodPost, refreshURL, h.RefreshToken)
+}
+
+func (h *Handler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("HANDLER: CREATE TOKEN")
+
+	var input CreateTokenDTO
+	if err := response.ReadJSON(w, r, &input); err != nil {
+		response.BadRequest(w, r, err.Error(), apperror.ErrInvalidRequestBody.Error())
+		return
+	}
+
+	tokens, err := h.authService.Issue(r.Context(), &input)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	log.Info("TOKEN HAS BEEN ISSUED")
+	response.JSON(w, http.StatusOK, tokens)
+}
+
+func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("HANDLER: REFRESH TOKEN")
+
+	var input RefreshTokenDTO
+	if err := response.ReadJSON(w, r, &input); err != nil {
+		response.BadRequest(w, r, err.Error(), apperror.ErrInvalidRequestBody.Error())
+		return
+	}
+
+	tokens, err := h.authService.Refresh(r.Context(), &input)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	log.Info("TOKEN HAS BEEN REFRESHED")
+	response.JSON(w, http.StatusOK, tokens)
+}
+
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrInvalidCredentials) || errors.Is(err, ErrInvalidToken) {
+		response.ErrorAuth(w, r, err.Error(), "")
+		return
+	}
+	response.InternalError(w, r, err.Error(), "")
+}
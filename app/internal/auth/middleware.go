@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"WBL0/app/internal/handler"
+	"WBL0/app/internal/response"
+	"WBL0/app/pkg/logger"
+	"context"
+	"net/http"
+	"strings"
+)
+
+type claimsKey struct{}
+
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
+
+// Router wraps every route registered through it with a bearer token check
+// and a check of the permission the route's handler declares for it.
+type Router struct {
+	router      handler.Router
+	authService Service
+	permissions handler.Permissions
+	public      map[string]bool
+	log         logger.Logger
+}
+
+func NewRouter(r handler.Router, authService Service, log logger.Logger) *Router {
+	return &Router{
+		router:      r,
+		authService: authService,
+		permissions: handler.Permissions{},
+		public:      map[string]bool{},
+		log:         log,
+	}
+}
+
+// Expose opens routes, see handler.Route, to anonymous callers whatever permission their
+// handlers declare. It has to be called before the handlers of the routes are mounted.
+func (r *Router) Expose(routes ...string) {
+	for _, route := range routes {
+		r.public[route] = true
+	}
+}
+
+// Mount registers the routes of h under the permissions h declares.
+func (r *Router) Mount(h handler.Hand) {
+	for route, permission := range h.Permissions() {
+		r.permissions[route] = permission
+	}
+	h.Register(r)
+}
+
+func (r *Router) HandlerFunc(method, path string, next http.HandlerFunc) {
+	permission, ok := r.permissions[handler.Route(method, path)]
+	if !ok {
+		// A route nobody declared a permission for is left to admins rather than opened up
+		r.log.Warnf("no permission declared for %s %s, requiring %s", method, path, handler.PermissionAdmin)
+		permission = handler.PermissionAdmin
+	}
+	if r.public[handler.Route(method, path)] && permission != handler.PermissionPublic {
+		r.log.Warnf("%s %s is exposed without authentication instead of requiring %s", method, path, permission)
+		permission = handler.PermissionPublic
+	}
+	if permission == handler.PermissionPublic {
+		r.router.HandlerFunc(method, path, next)
+		return
+	}
+	r.router.HandlerFunc(method, path, r.authenticate(permission, next))
+}
+
+func (r *Router) authenticate(permission handler.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			response.ErrorAuth(w, req, "missing bearer token", "")
+			return
+		}
+
+		claims, err := r.authService.Verify(token)
+		if err != nil {
+			logger.FromContext(req.Context(), r.log).Warnf("rejected token for %s %s", req.Method, req.URL.Path)
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			response.ErrorAuth(w, req, err.Error(), "")
+			return
+		}
+
+		if !claims.Allows(permission) {
+			logger.FromContext(req.Context(), r.log).Warnf("client %s with roles %v denied %s %s", claims.Subject, claims.Roles, req.Method, req.URL.Path)
+			response.Forbidden(w, req, "missing permission "+string(permission), "")
+			return
+		}
+
+		next(w, req.WithContext(context.WithValue(req.Context(), claimsKey{}, claims)))
+	}
+}
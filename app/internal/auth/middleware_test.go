@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"WBL0/app/internal/handler"
+	"WBL0/app/pkg/logger"
+	"context"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testHandler struct {
+	permissions handler.Permissions
+	routes      []string
+}
+
+func (h *testHandler) Permissions() handler.Permissions {
+	return h.permissions
+}
+
+func (h *testHandler) Register(router handler.Router) {
+	for _, path := range h.routes {
+		router.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// testRouter mounts GET routes under the given permissions on an auth router.
+func testRouter(s Service, permissions map[string]handler.Permission, undeclared ...string) http.Handler {
+	router := httprouter.New()
+	h := &testHandler{permissions: handler.Permissions{}, routes: undeclared}
+	for path, permission := range permissions {
+		h.permissions[handler.Route(http.MethodGet, path)] = permission
+		h.routes = append(h.routes, path)
+	}
+	NewRouter(router, s, logger.GetLogger()).Mount(h)
+	return router
+}
+
+func serve(router http.Handler, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRouterRejectsMissingAndInvalidTokens(t *testing.T) {
+	cfg := testConfig()
+	s := testService(cfg)
+	router := testRouter(s, map[string]handler.Permission{"/orders": handler.PermissionRead})
+
+	tokens, err := s.Issue(context.Background(), &CreateTokenDTO{ClientID: "support", ClientSecret: cfg.JWT.ClientSecrets["support"]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := s.sign("support", []string{RoleSupport}, TokenAccess, -time.Minute, cfg.JWT.AccessTokenSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"missing token": "",
+		"garbage token": "not-a-jwt",
+		"expired token": expired,
+		"refresh token": tokens.RefreshToken,
+	}
+	for name, token := range cases {
+		w := serve(router, "/orders", token)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %d", name, w.Code)
+		}
+		if w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: expected a WWW-Authenticate header", name)
+		}
+	}
+
+	if w := serve(router, "/orders", tokens.AccessToken); w.Code != http.StatusNoContent {
+		t.Fatalf("expected a valid token to be accepted, got %d", w.Code)
+	}
+}
+
+func TestRouterRolePermissions(t *testing.T) {
+	cfg := testConfig()
+	s := testService(cfg)
+	router := testRouter(s, map[string]handler.Permission{
+		"/public":  handler.PermissionPublic,
+		"/read":    handler.PermissionRead,
+		"/publish": handler.PermissionPublish,
+		"/admin":   handler.PermissionAdmin,
+	}, "/undeclared")
+
+	tokens := map[string]string{}
+	for _, client := range []string{"support", "publisher", "admin"} {
+		pair, err := s.Issue(context.Background(), &CreateTokenDTO{ClientID: client, ClientSecret: cfg.JWT.ClientSecrets[client]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens[client] = pair.AccessToken
+	}
+	noRoles, err := s.sign("nobody", nil, TokenAccess, time.Minute, cfg.JWT.AccessTokenSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens["no roles"] = noRoles
+
+	cases := []struct {
+		client string
+		path   string
+		want   int
+	}{
+		{"anonymous", "/public", http.StatusNoContent},
+		{"anonymous", "/read", http.StatusUnauthorized},
+		{"anonymous", "/undeclared", http.StatusUnauthorized},
+
+		{"support", "/public", http.StatusNoContent},
+		{"support", "/read", http.StatusNoContent},
+		{"support", "/publish", http.StatusForbidden},
+		{"support", "/admin", http.StatusForbidden},
+		{"support", "/undeclared", http.StatusForbidden},
+
+		{"publisher", "/read", http.StatusForbidden},
+		{"publisher", "/publish", http.StatusNoContent},
+		{"publisher", "/admin", http.StatusForbidden},
+		{"publisher", "/undeclared", http.StatusForbidden},
+
+		{"admin", "/read", http.StatusNoContent},
+		{"admin", "/publish", http.StatusNoContent},
+		{"admin", "/admin", http.StatusNoContent},
+		{"admin", "/undeclared", http.StatusNoContent},
+
+		{"no roles", "/read", http.StatusForbidden},
+		{"no roles", "/public", http.StatusNoContent},
+	}
+	for _, c := range cases {
+		if w := serve(router, c.path, tokens[c.client]); w.Code != c.want {
+			t.Errorf("%s GET %s: got %d, want %d", c.client, c.path, w.Code, c.want)
+		}
+	}
+}
+
+func TestRouterExpose(t *testing.T) {
+	s := testService(testConfig())
+
+	router := httprouter.New()
+	authRouter := NewRouter(router, s, logger.GetLogger())
+	authRouter.Expose(handler.Route(http.MethodGet, "/order/:id"))
+	authRouter.Mount(&testHandler{
+		permissions: handler.Permissions{
+			handler.Route(http.MethodGet, "/order/:id"): handler.PermissionRead,
+			handler.Route(http.MethodGet, "/orders"):    handler.PermissionRead,
+		},
+		routes: []string{"/order/:id", "/orders"},
+	})
+
+	if w := serve(router, "/order/b563feb7b2b84b6test", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected an exposed route to be public, got %d", w.Code)
+	}
+	if w := serve(router, "/orders", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected other routes to keep their permission, got %d", w.Code)
+	}
+}
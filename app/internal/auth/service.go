@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"WBL0/app/pkg/config"
+	"WBL0/app/pkg/logger"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"time"
+)
+
+type Service interface {
+	Issue(ctx context.Context, dto *CreateTokenDTO) (*TokenPair, error)
+	Refresh(ctx context.Context, dto *RefreshTokenDTO) (*TokenPair, error)
+	Verify(token string) (*Claims, error)
+}
+
+type service struct {
+	cfg config.Config
+	log logger.Logger
+}
+
+const (
+	minKeyLength    = 32
+	minSecretLength = 16
+)
+
+// defaultSecrets were committed to config.yml before the keys and client secrets moved to the environment.
+var defaultSecrets = map[string]bool{
+	"maks":             true,
+	"1992":             true,
+	"publisher-secret": true,
+	"support-secret":   true,
+	"admin-secret":     true,
+}
+
+// ValidateConfig refuses signing keys and client secrets that are missing, too short or
+// still the former defaults, so the server never starts with tokens anyone can forge.
+func ValidateConfig(cfg config.Config) error {
+	keys := map[string]string{
+		"JWT_ACCESS_SECRET":  cfg.JWT.AccessTokenSecretKey,
+		"JWT_REFRESH_SECRET": cfg.JWT.RefreshTokenSecretKey,
+	}
+	for name, key := range keys {
+		if len(key) < minKeyLength || defaultSecrets[key] {
+			return fmt.Errorf("%s must be set to a random key of at least %d bytes", name, minKeyLength)
+		}
+	}
+	if cfg.JWT.AccessTokenSecretKey == cfg.JWT.RefreshTokenSecretKey {
+		return fmt.Errorf("JWT_ACCESS_SECRET and JWT_REFRESH_SECRET must differ")
+	}
+
+	for _, c := range cfg.JWT.Clients {
+		secret := cfg.JWT.ClientSecrets[c.ID]
+		if len(secret) < minSecretLength || defaultSecrets[secret] {
+			return fmt.Errorf("JWT_CLIENT_SECRETS must set a random secret of at least %d bytes for client %q", minSecretLength, c.ID)
+		}
+	}
+	return nil
+}
+
+func NewService(cfg config.Config, log logger.Logger) Service {
+	return &service{
+		cfg: cfg,
+		log: log,
+	}
+}
+
+func (s *service) Issue(ctx context.Context, dto *CreateTokenDTO) (*TokenPair, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: ISSUE TOKEN")
+
+	roles, ok := s.client(dto.ClientID, &dto.ClientSecret)
+	if !ok {
+		log.Warnf("rejected credentials for client %q", dto.ClientID)
+		return nil, ErrInvalidCredentials
+	}
+	return s.newPair(dto.ClientID, roles)
+}
+
+func (s *service) Refresh(ctx context.Context, dto *RefreshTokenDTO) (*TokenPair, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: REFRESH TOKEN")
+
+	claims, err := s.parse(dto.RefreshToken, TokenRefresh, s.cfg.JWT.RefreshTokenSecretKey)
+	if err != nil {
+		return nil, err
+	}
+	// Roles are read from the config again, so a removed client or a changed role
+	// takes effect on the next refresh rather than when the refresh token expires
+	roles, ok := s.client(claims.Subject, nil)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return s.newPair(claims.Subject, roles)
+}
+
+func (s *service) Verify(token string) (*Claims, error) {
+	return s.parse(token, TokenAccess, s.cfg.JWT.AccessTokenSecretKey)
+}
+
+// client returns the roles of a configured client, checking its secret unless it is nil.
+func (s *service) client(id string, secret *string) ([]string, bool) {
+	for _, c := range s.cfg.JWT.Clients {
+		if c.ID != id {
+			continue
+		}
+		if secret != nil && subtle.ConstantTimeCompare([]byte(s.cfg.JWT.ClientSecrets[c.ID]), []byte(*secret)) != 1 {
+			return nil, false
+		}
+		return c.Roles, true
+	}
+	return nil, false
+}
+
+func (s *service) newPair(clientID string, roles []string) (*TokenPair, error) {
+	accessTTL := time.Duration(s.cfg.JWT.AccessExpirationMinutes) * time.Minute
+	refreshTTL := time.Duration(s.cfg.JWT.RefreshExpirationDays) * 24 * time.Hour
+
+	access, err := s.sign(clientID, roles, TokenAccess, accessTTL, s.cfg.JWT.AccessTokenSecretKey)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := s.sign(clientID, nil, TokenRefresh, refreshTTL, s.cfg.JWT.RefreshTokenSecretKey)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(accessTTL.Seconds()),
+	}, nil
+}
+
+func (s *service) sign(clientID string, roles []string, tokenType string, ttl time.Duration, key string) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		Type:  tokenType,
+		Roles: roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.cfg.JWT.Issuer,
+			Subject:   clientID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(key))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign %s token: %v", tokenType, err)
+	}
+	return token, nil
+}
+
+func (s *service) parse(token, tokenType, key string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(key), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(s.cfg.JWT.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || claims.Type != tokenType {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"WBL0/app/pkg/config"
+	"WBL0/app/pkg/logger"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testConfig() config.Config {
+	var cfg config.Config
+	cfg.JWT.AccessExpirationMinutes = 10
+	cfg.JWT.RefreshExpirationDays = 15
+	cfg.JWT.AccessTokenSecretKey = strings.Repeat("a", minKeyLength)
+	cfg.JWT.RefreshTokenSecretKey = strings.Repeat("r", minKeyLength)
+	cfg.JWT.Issuer = "WBL0"
+	cfg.JWT.ClientSecrets = map[string]string{
+		"publisher": "publisher-0123456789",
+		"support":   "support-0123456789",
+		"admin":     "admin-0123456789ab",
+	}
+	for id, role := range map[string]string{"publisher": RoleIntegration, "support": RoleSupport, "admin": RoleAdmin} {
+		cfg.JWT.Clients = append(cfg.JWT.Clients, struct {
+			ID    string   `yaml:"id"`
+			Roles []string `yaml:"roles"`
+		}{ID: id, Roles: []string{role}})
+	}
+	return cfg
+}
+
+func testService(cfg config.Config) *service {
+	return NewService(cfg, logger.GetLogger()).(*service)
+}
+
+func TestValidateConfig(t *testing.T) {
+	if err := ValidateConfig(testConfig()); err != nil {
+		t.Fatalf("expected the test config to be valid: %v", err)
+	}
+
+	cases := map[string]func(cfg *config.Config){
+		"empty access key":      func(cfg *config.Config) { cfg.JWT.AccessTokenSecretKey = "" },
+		"default access key":    func(cfg *config.Config) { cfg.JWT.AccessTokenSecretKey = "maks" },
+		"default refresh key":   func(cfg *config.Config) { cfg.JWT.RefreshTokenSecretKey = "1992" },
+		"short refresh key":     func(cfg *config.Config) { cfg.JWT.RefreshTokenSecretKey = "0123456789" },
+		"same keys":             func(cfg *config.Config) { cfg.JWT.RefreshTokenSecretKey = cfg.JWT.AccessTokenSecretKey },
+		"missing client secret": func(cfg *config.Config) { delete(cfg.JWT.ClientSecrets, "support") },
+		"default client secret": func(cfg *config.Config) { cfg.JWT.ClientSecrets["admin"] = "admin-secret" },
+		"short client secret":   func(cfg *config.Config) { cfg.JWT.ClientSecrets["publisher"] = "short" },
+	}
+	for name, change := range cases {
+		cfg := testConfig()
+		change(&cfg)
+		if err := ValidateConfig(cfg); err == nil {
+			t.Errorf("%s: expected the config to be rejected", name)
+		}
+	}
+}
+
+func TestIssueAndVerify(t *testing.T) {
+	cfg := testConfig()
+	s := testService(cfg)
+
+	tokens, err := s.Issue(context.Background(), &CreateTokenDTO{ClientID: "support", ClientSecret: cfg.JWT.ClientSecrets["support"]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.TokenType != "Bearer" || tokens.ExpiresIn != 600 {
+		t.Fatalf("unexpected token pair: %+v", tokens)
+	}
+
+	claims, err := s.Verify(tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "support" || claims.Type != TokenAccess || len(claims.Roles) != 1 || claims.Roles[0] != RoleSupport {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+
+	// A refresh token must not pass as an access token
+	if _, err = s.Verify(tokens.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected a refresh token to be rejected by Verify, got %v", err)
+	}
+}
+
+func TestIssueRejectsInvalidCredentials(t *testing.T) {
+	cfg := testConfig()
+	s := testService(cfg)
+
+	cases := map[string]*CreateTokenDTO{
+		"wrong secret":   {ClientID: "admin", ClientSecret: cfg.JWT.ClientSecrets["support"]},
+		"empty secret":   {ClientID: "admin"},
+		"unknown client": {ClientID: "nobody", ClientSecret: cfg.JWT.ClientSecrets["admin"]},
+	}
+	for name, dto := range cases {
+		if _, err := s.Issue(context.Background(), dto); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s: expected ErrInvalidCredentials, got %v", name, err)
+		}
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	cfg := testConfig()
+	s := testService(cfg)
+
+	tokens, err := s.Issue(context.Background(), &CreateTokenDTO{ClientID: "publisher", ClientSecret: cfg.JWT.ClientSecrets["publisher"]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed, err := s.Refresh(context.Background(), &RefreshTokenDTO{RefreshToken: tokens.RefreshToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := s.Verify(refreshed.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "publisher" || len(claims.Roles) != 1 || claims.Roles[0] != RoleIntegration {
+		t.Fatalf("unexpected refreshed claims: %+v", claims)
+	}
+
+	if _, err = s.Refresh(context.Background(), &RefreshTokenDTO{RefreshToken: tokens.AccessToken}); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected an access token to be rejected by Refresh, got %v", err)
+	}
+
+	// A client removed from the config cannot refresh its tokens
+	cfg.JWT.Clients = cfg.JWT.Clients[:0]
+	if _, err = testService(cfg).Refresh(context.Background(), &RefreshTokenDTO{RefreshToken: tokens.RefreshToken}); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected a removed client to be rejected, got %v", err)
+	}
+}
+
+func TestExpiredAndForeignTokens(t *testing.T) {
+	cfg := testConfig()
+	s := testService(cfg)
+
+	expired, err := s.sign("admin", []string{RoleAdmin}, TokenAccess, -time.Minute, cfg.JWT.AccessTokenSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Verify(expired); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected an expired access token to be rejected, got %v", err)
+	}
+
+	expiredRefresh, err := s.sign("admin", nil, TokenRefresh, -time.Minute, cfg.JWT.RefreshTokenSecretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Refresh(context.Background(), &RefreshTokenDTO{RefreshToken: expiredRefresh}); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected an expired refresh token to be rejected, got %v", err)
+	}
+
+	foreign, err := s.sign("admin", []string{RoleAdmin}, TokenAccess, time.Minute, strings.Repeat("x", minKeyLength))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Verify(foreign); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected a token signed with another key to be rejected, got %v", err)
+	}
+}
@@ -2,20 +2,111 @@ package cache
 
 import (
 	"WBL0/app/internal/model"
+	"sync"
 )
 
 type Cache struct {
-	Deliveries map[int64]*model.Delivery
-	Payments   map[int64]*model.Payment
-	Items      map[int64]*model.Item
-	Orders     map[string]*model.Order
+	deliveries *Store[int64, *model.Delivery]
+	payments   *Store[int64, *model.Payment]
+	items      *Store[int64, *model.Item]
+	orders     *Store[string, *model.Order]
+	index      *orderIndex
+	bounded    bool
+
+	snapshotMu sync.Mutex
+
+	loadOnce sync.Once
+	loaded   chan struct{}
+	loadErr  error
 }
 
+const (
+	ModeFull    = "full"
+	ModeBounded = "bounded"
+)
+
 func NewCache() *Cache {
+	return newCache(Limits{}, false)
+}
+
+// NewBoundedCache returns a cache whose stores each hold entries within limits. Such a cache
+// holds only part of the DB, so a miss has to be read through from it.
+func NewBoundedCache(limits Limits) *Cache {
+	return newCache(limits, true)
+}
+
+func newCache(limits Limits, bounded bool) *Cache {
+	index := newOrderIndex()
 	return &Cache{
-		Deliveries: make(map[int64]*model.Delivery),
-		Payments:   make(map[int64]*model.Payment),
-		Items:      make(map[int64]*model.Item),
-		Orders:     make(map[string]*model.Order),
+		deliveries: NewBoundedStore[int64, *model.Delivery](limits),
+		payments:   NewBoundedStore[int64, *model.Payment](limits),
+		items:      newHookedStore[int64, *model.Item](limits, itemHook{index}),
+		orders:     newHookedStore[string, *model.Order](limits, orderHook{index}),
+		index:      index,
+		bounded:    bounded,
+		loaded:     make(chan struct{}),
+	}
+}
+
+// Bounded reports whether the cache holds only part of the DB.
+func (c *Cache) Bounded() bool {
+	return c.bounded
+}
+
+// FinishLoad marks the warm-up from the database as done, with the error it failed with if any.
+func (c *Cache) FinishLoad(err error) {
+	c.loadOnce.Do(func() {
+		c.loadErr = err
+		close(c.loaded)
+	})
+}
+
+// Loaded is closed once the warm-up has finished, successfully or not.
+func (c *Cache) Loaded() <-chan struct{} {
+	return c.loaded
+}
+
+func (c *Cache) LoadState() (done bool, err error) {
+	select {
+	case <-c.loaded:
+		return true, c.loadErr
+	default:
+		return false, nil
+	}
+}
+
+func (c *Cache) Deliveries() *Store[int64, *model.Delivery] {
+	return c.deliveries
+}
+
+func (c *Cache) Payments() *Store[int64, *model.Payment] {
+	return c.payments
+}
+
+func (c *Cache) Items() *Store[int64, *model.Item] {
+	return c.items
+}
+
+func (c *Cache) Orders() *Store[string, *model.Order] {
+	return c.orders
+}
+
+// OrdersByTrack returns the cached orders whose own track number or one of whose items' is track.
+func (c *Cache) OrdersByTrack(track string) []*model.Order {
+	return c.ordersByUid(c.index.track(track))
+}
+
+func (c *Cache) OrdersByCustomer(customerID string) []*model.Order {
+	return c.ordersByUid(c.index.customer(customerID))
+}
+
+func (c *Cache) ordersByUid(uids []string) []*model.Order {
+	orders := make([]*model.Order, 0, len(uids))
+	for _, uid := range uids {
+		// The order can be deleted between the index lookup and here
+		if o, ok := c.orders.Get(uid); ok {
+			orders = append(orders, o)
+		}
 	}
+	return orders
 }
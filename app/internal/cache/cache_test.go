@@ -0,0 +1,255 @@
+package cache
+
+import (
+	"WBL0/app/internal/model"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	workers    = 8
+	iterations = 1000
+)
+
+func TestStoreGetSetDelete(t *testing.T) {
+	s := NewStore[int64, *model.Delivery]()
+
+	if _, ok := s.Get(1); ok {
+		t.Fatal("expected miss on empty store")
+	}
+
+	s.Set(1, &model.Delivery{ID: 1, Name: "Test Testov"})
+	got, ok := s.Get(1)
+	if !ok || got.Name != "Test Testov" {
+		t.Fatalf("unexpected value after Set: %+v, %v", got, ok)
+	}
+	if s.Len() != 1 {
+		t.Fatalf("expected length 1, got %d", s.Len())
+	}
+
+	s.Delete(1)
+	if _, ok = s.Get(1); ok {
+		t.Fatal("expected miss after Delete")
+	}
+	if s.Len() != 0 {
+		t.Fatalf("expected length 0, got %d", s.Len())
+	}
+}
+
+func TestStoreRangeStopsEarly(t *testing.T) {
+	s := NewStore[int64, *model.Item]()
+	for i := int64(1); i <= 10; i++ {
+		s.Set(i, &model.Item{ID: i})
+	}
+
+	visited := 0
+	s.Range(func(key int64, value *model.Item) bool {
+		visited++
+		return visited < 3
+	})
+	if visited != 3 {
+		t.Fatalf("expected Range to stop after 3 entries, visited %d", visited)
+	}
+}
+
+func TestStoreRangeAllowsWrites(t *testing.T) {
+	s := NewStore[int64, *model.Item]()
+	for i := int64(1); i <= 10; i++ {
+		s.Set(i, &model.Item{ID: i})
+	}
+
+	s.Range(func(key int64, value *model.Item) bool {
+		s.Delete(key)
+		return true
+	})
+	if s.Len() != 0 {
+		t.Fatalf("expected empty store, got %d entries", s.Len())
+	}
+}
+
+// Run with -race: readers, writers, deleters and rangers hit every store at once.
+func TestCacheConcurrentAccess(t *testing.T) {
+	c := NewCache()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(4)
+
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				id := int64(w*iterations + i)
+				c.Deliveries().Set(id, &model.Delivery{ID: id})
+				c.Payments().Set(id, &model.Payment{ID: id})
+				c.Items().Set(id, &model.Item{ID: id})
+				c.Orders().Set(fmt.Sprint(id), &model.Order{OrderUID: fmt.Sprint(id)})
+			}
+		}(w)
+
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				id := int64(w*iterations + i)
+				c.Deliveries().Get(id)
+				c.Payments().Get(id)
+				c.Items().Get(id)
+				c.Orders().Get(fmt.Sprint(id))
+			}
+		}(w)
+
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i += 2 {
+				id := int64(w*iterations + i)
+				c.Items().Delete(id)
+			}
+		}(w)
+
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations/100; i++ {
+				c.Orders().Range(func(key string, value *model.Order) bool {
+					return value.OrderUID == key
+				})
+				c.Payments().Len()
+			}
+		}()
+	}
+	wg.Wait()
+
+	total := workers * iterations
+	if n := c.Deliveries().Len(); n != total {
+		t.Fatalf("expected %d deliveries, got %d", total, n)
+	}
+	if n := c.Orders().Len(); n != total {
+		t.Fatalf("expected %d orders, got %d", total, n)
+	}
+	if n := c.Items().Len(); n < total/2 || n > total {
+		t.Fatalf("unexpected number of items after concurrent deletes: %d", n)
+	}
+}
+
+func orderUids(orders []*model.Order) []string {
+	uids := []string{}
+	for _, o := range orders {
+		uids = append(uids, o.OrderUID)
+	}
+	return uids
+}
+
+func TestCacheIndexesFollowOrdersAndItems(t *testing.T) {
+	c := NewCache()
+
+	// The order is cached before its items, the other order after them
+	c.Orders().Set("a", &model.Order{OrderUID: "a", TrackNumber: "T1", CustomerID: "test", Items: []int64{1, 2}})
+	c.Items().Set(1, &model.Item{ID: 1, TrackNumber: "T1"})
+	c.Items().Set(2, &model.Item{ID: 2, TrackNumber: "T2"})
+	c.Items().Set(3, &model.Item{ID: 3, TrackNumber: "T2"})
+	c.Orders().Set("b", &model.Order{OrderUID: "b", TrackNumber: "T3", CustomerID: "test", Items: []int64{3}})
+
+	if got := fmt.Sprint(orderUids(c.OrdersByTrack("T2"))); got != "[a b]" {
+		t.Fatalf("unexpected orders by item track: %s", got)
+	}
+	if got := fmt.Sprint(orderUids(c.OrdersByCustomer("test"))); got != "[a b]" {
+		t.Fatalf("unexpected orders by customer: %s", got)
+	}
+
+	// T1 is on order a and on its item 1, so it stays indexed until both are gone
+	c.Items().Delete(1)
+	if got := fmt.Sprint(orderUids(c.OrdersByTrack("T1"))); got != "[a]" {
+		t.Fatalf("unexpected orders by track after item delete: %s", got)
+	}
+
+	c.Orders().Set("a", &model.Order{OrderUID: "a", TrackNumber: "T4", CustomerID: "other"})
+	if got := fmt.Sprint(orderUids(c.OrdersByTrack("T1"))); got != "[]" {
+		t.Fatalf("unexpected orders by old track after replace: %s", got)
+	}
+	if got := fmt.Sprint(orderUids(c.OrdersByTrack("T2"))); got != "[b]" {
+		t.Fatalf("unexpected orders by old item track after replace: %s", got)
+	}
+	if got := fmt.Sprint(orderUids(c.OrdersByCustomer("other"))); got != "[a]" {
+		t.Fatalf("unexpected orders by new customer after replace: %s", got)
+	}
+
+	c.Orders().Delete("b")
+	if got := fmt.Sprint(orderUids(c.OrdersByCustomer("test"))); got != "[]" {
+		t.Fatalf("unexpected orders by customer after delete: %s", got)
+	}
+	if got := fmt.Sprint(orderUids(c.OrdersByTrack("T2"))); got != "[]" {
+		t.Fatalf("unexpected orders by item track after delete: %s", got)
+	}
+}
+
+func TestBoundedStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	s := NewBoundedStore[int64, *model.Item](Limits{MaxEntries: 2})
+	s.Set(1, &model.Item{ID: 1})
+	s.Set(2, &model.Item{ID: 2})
+	s.Get(1)
+	s.Set(3, &model.Item{ID: 3})
+
+	if _, ok := s.Get(2); ok {
+		t.Fatal("expected the least recently used entry to be evicted")
+	}
+	for _, id := range []int64{1, 3} {
+		if _, ok := s.Get(id); !ok {
+			t.Fatalf("expected entry %d to be kept", id)
+		}
+	}
+	if stats := s.Stats(); stats.Entries != 2 || stats.Evictions != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestBoundedStoreLimitsBytes(t *testing.T) {
+	item := &model.Item{Name: "Mascaras"}
+	s := NewBoundedStore[int64, *model.Item](Limits{MaxBytes: 2*sizeOf(item) + 1})
+	for id := int64(1); id <= 5; id++ {
+		s.Set(id, item)
+	}
+	if stats := s.Stats(); stats.Entries != 2 || stats.Bytes != 2*sizeOf(item) || stats.Evictions != 3 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestBoundedStoreExpiresEntries(t *testing.T) {
+	s := NewBoundedStore[int64, *model.Item](Limits{TTL: time.Millisecond})
+	s.Set(1, &model.Item{ID: 1})
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := s.Get(1); ok {
+		t.Fatal("expected the entry to expire")
+	}
+	if stats := s.Stats(); stats.Entries != 0 || stats.Expirations != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestStoreAddKeepsCachedValue(t *testing.T) {
+	s := NewStore[int64, *model.Item]()
+	s.Set(1, &model.Item{ID: 1, Name: "ingested"})
+
+	if s.Add(1, &model.Item{ID: 1, Name: "read through"}) {
+		t.Fatal("expected Add to keep the cached value")
+	}
+	if got, _ := s.Get(1); got.Name != "ingested" {
+		t.Fatalf("unexpected value after Add: %+v", got)
+	}
+	if !s.Add(2, &model.Item{ID: 2}) {
+		t.Fatal("expected Add to store a missing value")
+	}
+}
+
+func TestBoundedCacheEvictionUpdatesIndexes(t *testing.T) {
+	c := NewBoundedCache(Limits{MaxEntries: 1})
+	c.Orders().Set("a", &model.Order{OrderUID: "a", TrackNumber: "T1", CustomerID: "test"})
+	c.Orders().Set("b", &model.Order{OrderUID: "b", TrackNumber: "T2", CustomerID: "test"})
+
+	if got := fmt.Sprint(orderUids(c.OrdersByCustomer("test"))); got != "[b]" {
+		t.Fatalf("unexpected orders by customer after eviction: %s", got)
+	}
+	if got := fmt.Sprint(orderUids(c.OrdersByTrack("T1"))); got != "[]" {
+		t.Fatalf("unexpected orders by evicted track: %s", got)
+	}
+}
@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"WBL0/app/internal/model"
+	"sort"
+	"sync"
+)
+
+// refSet counts the references from a secondary key to each order_uid, since one
+// order can reach the same track number through itself and through several items.
+type refSet map[string]map[string]int
+
+func (s refSet) add(key, uid string) {
+	if key == "" {
+		return
+	}
+	if s[key] == nil {
+		s[key] = map[string]int{}
+	}
+	s[key][uid]++
+}
+
+func (s refSet) remove(key, uid string) {
+	uids, ok := s[key]
+	if !ok {
+		return
+	}
+	if uids[uid]--; uids[uid] <= 0 {
+		delete(uids, uid)
+	}
+	if len(uids) == 0 {
+		delete(s, key)
+	}
+}
+
+func (s refSet) get(key string) []string {
+	uids := make([]string, 0, len(s[key]))
+	for uid := range s[key] {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+	return uids
+}
+
+// orderIndex maps track numbers, of orders and of their items, and customer IDs to order_uids.
+// It is kept up to date by hooks on the order and item stores, whichever of them is set first.
+type orderIndex struct {
+	mu         sync.RWMutex
+	byTrack    refSet
+	byCustomer refSet
+	itemOrder  map[int64]string
+	itemTrack  map[int64]string
+}
+
+func newOrderIndex() *orderIndex {
+	return &orderIndex{
+		byTrack:    refSet{},
+		byCustomer: refSet{},
+		itemOrder:  map[int64]string{},
+		itemTrack:  map[int64]string{},
+	}
+}
+
+func (x *orderIndex) addOrder(o *model.Order) {
+	x.byTrack.add(o.TrackNumber, o.OrderUID)
+	x.byCustomer.add(o.CustomerID, o.OrderUID)
+	for _, id := range o.Items {
+		x.itemOrder[id] = o.OrderUID
+		if track, ok := x.itemTrack[id]; ok {
+			x.byTrack.add(track, o.OrderUID)
+		}
+	}
+}
+
+func (x *orderIndex) removeOrder(o *model.Order) {
+	x.byTrack.remove(o.TrackNumber, o.OrderUID)
+	x.byCustomer.remove(o.CustomerID, o.OrderUID)
+	for _, id := range o.Items {
+		if x.itemOrder[id] != o.OrderUID {
+			continue
+		}
+		delete(x.itemOrder, id)
+		if track, ok := x.itemTrack[id]; ok {
+			x.byTrack.remove(track, o.OrderUID)
+		}
+	}
+}
+
+func (x *orderIndex) addItem(id int64, track string) {
+	x.itemTrack[id] = track
+	if uid, ok := x.itemOrder[id]; ok {
+		x.byTrack.add(track, uid)
+	}
+}
+
+func (x *orderIndex) removeItem(id int64) {
+	track, ok := x.itemTrack[id]
+	if !ok {
+		return
+	}
+	delete(x.itemTrack, id)
+	if uid, ok := x.itemOrder[id]; ok {
+		x.byTrack.remove(track, uid)
+	}
+}
+
+func (x *orderIndex) track(track string) []string {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	return x.byTrack.get(track)
+}
+
+func (x *orderIndex) customer(customerID string) []string {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	return x.byCustomer.get(customerID)
+}
+
+type orderHook struct {
+	*orderIndex
+}
+
+func (h orderHook) set(uid string, old *model.Order, replaced bool, o *model.Order) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if replaced {
+		h.removeOrder(old)
+	}
+	h.addOrder(o)
+}
+
+func (h orderHook) deleted(uid string, old *model.Order) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.removeOrder(old)
+}
+
+type itemHook struct {
+	*orderIndex
+}
+
+func (h itemHook) set(id int64, old *model.Item, replaced bool, i *model.Item) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if replaced {
+		h.removeItem(id)
+	}
+	h.addItem(id, i.TrackNumber)
+}
+
+func (h itemHook) deleted(id int64, old *model.Item) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.removeItem(id)
+}
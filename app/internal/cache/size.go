@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"WBL0/app/internal/model"
+	"unsafe"
+)
+
+// sizeOf approximates the memory held by a cached value: the struct itself
+// plus the strings and slices it points to, ignoring allocator overhead.
+func sizeOf(value any) int64 {
+	switch v := value.(type) {
+	case *model.Order:
+		return int64(unsafe.Sizeof(*v)) + int64(8*cap(v.Items)) + stringBytes(v.OrderUID, v.TrackNumber, v.Entry,
+			v.Locale, v.InternalSignature, v.CustomerID, v.DeliveryService, v.ShardKey, v.DateCreated, v.OofShard)
+	case *model.Delivery:
+		return int64(unsafe.Sizeof(*v)) + stringBytes(v.Name, v.Phone, v.Zip, v.City, v.Address, v.Region, v.Email)
+	case *model.Payment:
+		return int64(unsafe.Sizeof(*v)) + stringBytes(v.Transaction, v.RequestID, v.Currency, v.Provider, v.Bank)
+	case *model.Item:
+		return int64(unsafe.Sizeof(*v)) + stringBytes(v.TrackNumber, v.RID, v.Name, v.Size, v.Brand)
+	default:
+		return int64(unsafe.Sizeof(value))
+	}
+}
+
+func stringBytes(values ...string) int64 {
+	var n int64
+	for _, s := range values {
+		n += int64(len(s))
+	}
+	return n
+}
@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"WBL0/app/internal/model"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotVersion must be bumped whenever the model types change, so that an old
+// snapshot is rejected and the cache is reloaded from the DB instead.
+const snapshotVersion uint16 = 1
+
+var snapshotMagic = []byte("WBL0SNAP")
+
+var (
+	ErrSnapshotCorrupt = errors.New("cache snapshot is corrupt")
+	ErrSnapshotVersion = errors.New("cache snapshot version does not match")
+	ErrSnapshotPartial = errors.New("cache snapshot of a bounded cache cannot fill a full one")
+)
+
+// snapshot is written after a header of snapshotMagic and snapshotVersion, gzipped gob-encoded.
+type snapshot struct {
+	CreatedAt  time.Time
+	Watermark  int64
+	Bounded    bool
+	Orders     []*model.Order
+	Deliveries []*model.Delivery
+	Payments   []*model.Payment
+	Items      []*model.Item
+}
+
+type SnapshotInfo struct {
+	CreatedAt time.Time
+	// Watermark is the highest delivery ID of the orders in the snapshot. Every ingested order
+	// inserts a new delivery, so the orders with a higher one were stored after the snapshot.
+	// IDs are taken before commit, so a few orders just below it can have been committed later.
+	Watermark int64
+	Orders    int
+}
+
+// WriteSnapshot writes the cached entries to path. The file is replaced atomically,
+// so a crash while writing leaves the previous snapshot in place.
+func (c *Cache) WriteSnapshot(path string) (*SnapshotInfo, error) {
+	c.snapshotMu.Lock()
+	defer c.snapshotMu.Unlock()
+
+	// Orders are copied before their parts: ingestion caches the parts first,
+	// so every copied order finds its parts in the snapshot
+	s := snapshot{CreatedAt: time.Now(), Bounded: c.bounded}
+	c.orders.Range(func(uid string, o *model.Order) bool {
+		s.Orders = append(s.Orders, o)
+		if o.Delivery > s.Watermark {
+			s.Watermark = o.Delivery
+		}
+		return true
+	})
+	c.deliveries.Range(func(id int64, d *model.Delivery) bool {
+		s.Deliveries = append(s.Deliveries, d)
+		return true
+	})
+	c.payments.Range(func(id int64, p *model.Payment) bool {
+		s.Payments = append(s.Payments, p)
+		return true
+	})
+	c.items.Range(func(id int64, i *model.Item) bool {
+		s.Items = append(s.Items, i)
+		return true
+	})
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("cannot create snapshot directory: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("cannot create snapshot file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = encodeSnapshot(tmp, &s); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("cannot write snapshot: %v", err)
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("cannot write snapshot: %v", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return nil, fmt.Errorf("cannot write snapshot: %v", err)
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("cannot replace snapshot: %v", err)
+	}
+	return &SnapshotInfo{CreatedAt: s.CreatedAt, Watermark: s.Watermark, Orders: len(s.Orders)}, nil
+}
+
+// LoadSnapshot fills the cache from the snapshot at path. The snapshot is decoded in full
+// before anything is cached, so on an error the cache is left as it was.
+func (c *Cache) LoadSnapshot(path string) (*SnapshotInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s, err := decodeSnapshot(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+	if s.Bounded && !c.bounded {
+		return nil, ErrSnapshotPartial
+	}
+
+	deliveries := make(map[int64]bool, len(s.Deliveries))
+	for _, d := range s.Deliveries {
+		c.deliveries.Set(d.ID, d)
+		deliveries[d.ID] = true
+	}
+	payments := make(map[int64]bool, len(s.Payments))
+	for _, p := range s.Payments {
+		c.payments.Set(p.ID, p)
+		payments[p.ID] = true
+	}
+	items := make(map[int64]bool, len(s.Items))
+	for _, i := range s.Items {
+		c.items.Set(i.ID, i)
+		items[i.ID] = true
+	}
+
+	loaded := 0
+	for _, o := range s.Orders {
+		// An order replaced while the snapshot was written can miss its parts. It is left out,
+		// so that it is read through from the DB rather than never found complete in the cache.
+		if !deliveries[o.Delivery] || !payments[o.Payment] || !allCached(items, o.Items) {
+			continue
+		}
+		c.orders.Set(o.OrderUID, o)
+		loaded++
+	}
+	return &SnapshotInfo{CreatedAt: s.CreatedAt, Watermark: s.Watermark, Orders: loaded}, nil
+}
+
+func allCached(cached map[int64]bool, ids []int64) bool {
+	for _, id := range ids {
+		if !cached[id] {
+			return false
+		}
+	}
+	return true
+}
+
+func encodeSnapshot(w io.Writer, s *snapshot) error {
+	if _, err := w.Write(snapshotMagic); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, snapshotVersion); err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(w)
+	if err := gob.NewEncoder(zw).Encode(s); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func decodeSnapshot(r io.Reader) (*snapshot, error) {
+	magic := make([]byte, len(snapshotMagic))
+	var version uint16
+	if _, err := io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, snapshotMagic) {
+		return nil, ErrSnapshotCorrupt
+	}
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return nil, ErrSnapshotCorrupt
+	}
+	if version != snapshotVersion {
+		return nil, fmt.Errorf("%w: got %d, want %d", ErrSnapshotVersion, version, snapshotVersion)
+	}
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+	s := &snapshot{}
+	if err = gob.NewDecoder(zr).Decode(s); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+	// Reading to the end makes gzip verify its checksum and length
+	if _, err = io.Copy(io.Discard, zr); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"WBL0/app/internal/model"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func cachedOrder(c *Cache, uid string, id int64) {
+	c.Deliveries().Set(id, &model.Delivery{ID: id, Name: "Test Testov"})
+	c.Payments().Set(id, &model.Payment{ID: id, Transaction: uid})
+	c.Items().Set(id, &model.Item{ID: id, TrackNumber: "WBILMTESTTRACK"})
+	c.Orders().Set(uid, &model.Order{OrderUID: uid, TrackNumber: "WBILMTESTTRACK", Delivery: id, Payment: id, Items: []int64{id}})
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	c := NewCache()
+	cachedOrder(c, "a", 1)
+	cachedOrder(c, "b", 7)
+
+	written, err := c.WriteSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written.Watermark != 7 || written.Orders != 2 {
+		t.Fatalf("unexpected snapshot info: %+v", written)
+	}
+
+	restored := NewCache()
+	loaded, err := restored.LoadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Watermark != 7 || loaded.Orders != 2 {
+		t.Fatalf("unexpected loaded snapshot info: %+v", loaded)
+	}
+	if o, ok := restored.Orders().Get("b"); !ok || o.Delivery != 7 {
+		t.Fatalf("unexpected restored order: %+v, %v", o, ok)
+	}
+	if i, ok := restored.Items().Get(1); !ok || i.TrackNumber != "WBILMTESTTRACK" {
+		t.Fatalf("unexpected restored item: %+v, %v", i, ok)
+	}
+	if n := len(restored.OrdersByTrack("WBILMTESTTRACK")); n != 2 {
+		t.Fatalf("expected the track index to be rebuilt, got %d orders", n)
+	}
+}
+
+func TestSnapshotSkipsOrdersWithoutParts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	c := NewCache()
+	cachedOrder(c, "a", 1)
+	c.Orders().Set("b", &model.Order{OrderUID: "b", Delivery: 2, Payment: 2})
+	if _, err := c.WriteSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewCache()
+	if _, err := restored.LoadSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := restored.Orders().Get("b"); ok {
+		t.Fatal("expected the order without its parts to be skipped")
+	}
+}
+
+func TestSnapshotRejectsUnusableFiles(t *testing.T) {
+	dir := t.TempDir()
+	c := NewCache()
+	cachedOrder(c, "a", 1)
+	path := filepath.Join(dir, "cache.snapshot")
+	if _, err := c.WriteSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version := append([]byte{}, data...)
+	version[len(snapshotMagic)+1]++
+	truncated := data[:len(data)-4]
+	flipped := append([]byte{}, data...)
+	flipped[len(flipped)/2] ^= 0xff
+
+	cases := map[string]struct {
+		data []byte
+		err  error
+	}{
+		"version":   {version, ErrSnapshotVersion},
+		"truncated": {truncated, ErrSnapshotCorrupt},
+		"flipped":   {flipped, ErrSnapshotCorrupt},
+		"garbage":   {[]byte("not a snapshot"), ErrSnapshotCorrupt},
+	}
+	for name, tc := range cases {
+		path := filepath.Join(dir, name)
+		if err = os.WriteFile(path, tc.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		restored := NewCache()
+		if _, err = restored.LoadSnapshot(path); !errors.Is(err, tc.err) {
+			t.Errorf("%s: expected %v, got %v", name, tc.err, err)
+		}
+		if restored.Orders().Len() != 0 || restored.Items().Len() != 0 {
+			t.Errorf("%s: expected the cache to be left empty", name)
+		}
+	}
+}
+
+func TestSnapshotOfBoundedCacheDoesNotFillFullCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	c := NewBoundedCache(Limits{MaxEntries: 10})
+	cachedOrder(c, "a", 1)
+	if _, err := c.WriteSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewCache().LoadSnapshot(path); !errors.Is(err, ErrSnapshotPartial) {
+		t.Fatalf("expected %v, got %v", ErrSnapshotPartial, err)
+	}
+	if _, err := NewBoundedCache(Limits{MaxEntries: 10}).LoadSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+}
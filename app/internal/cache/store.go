@@ -0,0 +1,233 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Limits bound a store. A zero value keeps every entry until it is deleted.
+type Limits struct {
+	MaxEntries int
+	// MaxBytes limits the approximate size of the cached values, see sizeOf.
+	MaxBytes int64
+	TTL      time.Duration
+}
+
+func (l Limits) bounded() bool {
+	return l.MaxEntries > 0 || l.MaxBytes > 0 || l.TTL > 0
+}
+
+type Stats struct {
+	Entries     int
+	Bytes       int64
+	Evictions   uint64
+	Expirations uint64
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	size    int64
+	expires time.Time
+	elem    *list.Element
+}
+
+// Store is a map guarded by a RWMutex, safe for concurrent use. A bounded store
+// evicts the least recently used entries beyond its limits and drops expired ones.
+type Store[K comparable, V any] struct {
+	mu     sync.RWMutex
+	items  map[K]*entry[K, V]
+	hook   storeHook[K, V]
+	limits Limits
+	lru    *list.List
+	stats  Stats
+}
+
+// storeHook is told about every change of a store while the store's lock is held,
+// so that secondary indexes see changes of the same key in the order they happened.
+type storeHook[K comparable, V any] interface {
+	set(key K, old V, replaced bool, value V)
+	deleted(key K, old V)
+}
+
+func NewStore[K comparable, V any]() *Store[K, V] {
+	return &Store[K, V]{
+		items: make(map[K]*entry[K, V]),
+	}
+}
+
+// NewBoundedStore returns a store with the given limits, or an unbounded one for zero Limits.
+func NewBoundedStore[K comparable, V any](limits Limits) *Store[K, V] {
+	s := NewStore[K, V]()
+	if limits.bounded() {
+		s.limits = limits
+		s.lru = list.New()
+	}
+	return s
+}
+
+func newHookedStore[K comparable, V any](limits Limits, hook storeHook[K, V]) *Store[K, V] {
+	s := NewBoundedStore[K, V](limits)
+	s.hook = hook
+	return s
+}
+
+func (s *Store[K, V]) Get(key K) (V, bool) {
+	var zero V
+	if s.lru == nil {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		if e, ok := s.items[key]; ok {
+			return e.value, true
+		}
+		return zero, false
+	}
+
+	// Even a hit changes a bounded store, since it moves the entry to the front
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.items[key]
+	if !ok {
+		return zero, false
+	}
+	if s.expired(e, time.Now()) {
+		s.remove(e)
+		s.stats.Expirations++
+		return zero, false
+	}
+	s.lru.MoveToFront(e.elem)
+	return e.value, true
+}
+
+func (s *Store[K, V]) Set(key K, value V) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.set(key, value)
+}
+
+// Add stores value unless key is cached already, and reports whether it did. Values read
+// from the DB on a miss are added rather than set, so they never replace a newer ingested one.
+func (s *Store[K, V]) Add(key K, value V) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.items[key]; ok && !s.expired(e, time.Now()) {
+		return false
+	}
+	s.set(key, value)
+	return true
+}
+
+func (s *Store[K, V]) set(key K, value V) {
+	e, replaced := s.items[key]
+	if s.hook != nil {
+		var old V
+		if replaced {
+			old = e.value
+		}
+		s.hook.set(key, old, replaced, value)
+	}
+	if !replaced {
+		e = &entry[K, V]{key: key}
+		s.items[key] = e
+	}
+	e.value = value
+	if s.lru == nil {
+		return
+	}
+
+	s.stats.Bytes -= e.size
+	if s.limits.MaxBytes > 0 {
+		e.size = sizeOf(value)
+	}
+	s.stats.Bytes += e.size
+	if s.limits.TTL > 0 {
+		e.expires = time.Now().Add(s.limits.TTL)
+	}
+	if replaced {
+		s.lru.MoveToFront(e.elem)
+	} else {
+		e.elem = s.lru.PushFront(e)
+	}
+	s.evict()
+}
+
+// evict drops expired entries from the back of the LRU list, then the least recently
+// used ones while the store is over its limits. The newest entry is always kept.
+func (s *Store[K, V]) evict() {
+	now := time.Now()
+	for back := s.lru.Back(); back != nil && back != s.lru.Front(); back = s.lru.Back() {
+		e := back.Value.(*entry[K, V])
+		switch {
+		case s.expired(e, now):
+			s.stats.Expirations++
+		case s.limits.MaxEntries > 0 && len(s.items) > s.limits.MaxEntries,
+			s.limits.MaxBytes > 0 && s.stats.Bytes > s.limits.MaxBytes:
+			s.stats.Evictions++
+		default:
+			return
+		}
+		s.remove(e)
+	}
+}
+
+func (s *Store[K, V]) expired(e *entry[K, V], now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
+func (s *Store[K, V]) remove(e *entry[K, V]) {
+	if s.hook != nil {
+		s.hook.deleted(e.key, e.value)
+	}
+	delete(s.items, e.key)
+	if s.lru != nil {
+		s.lru.Remove(e.elem)
+		s.stats.Bytes -= e.size
+	}
+}
+
+func (s *Store[K, V]) Delete(key K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.items[key]; ok {
+		s.remove(e)
+	}
+}
+
+func (s *Store[K, V]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.items)
+}
+
+func (s *Store[K, V]) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := s.stats
+	stats.Entries = len(s.items)
+	return stats
+}
+
+// Range calls f for every entry until f returns false. It iterates over a
+// copy taken under the read lock, so f is free to modify the store.
+func (s *Store[K, V]) Range(f func(key K, value V) bool) {
+	s.mu.RLock()
+	entries := make([]entry[K, V], 0, len(s.items))
+	for _, e := range s.items {
+		entries = append(entries, entry[K, V]{key: e.key, value: e.value})
+	}
+	s.mu.RUnlock()
+
+	for _, e := range entries {
+		if !f(e.key, e.value) {
+			return
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package consistency
+
+import (
+	"WBL0/app/internal/order"
+	"fmt"
+	"time"
+)
+
+const (
+	ModeReject = "reject"
+	ModeFlag   = "flag"
+	ModeIgnore = "ignore"
+)
+
+const (
+	RuleItemTotal  = "item_total"
+	RuleGoodsTotal = "goods_total"
+	RuleAmount     = "amount"
+)
+
+type Violation struct {
+	Rule     string `json:"rule"`
+	Field    string `json:"field"`
+	Expected int    `json:"expected"`
+	Actual   int    `json:"actual"`
+}
+
+type FlaggedOrder struct {
+	OrderUID   string      `json:"order_uid"`
+	Violations []Violation `json:"violations"`
+	FlaggedAt  time.Time   `json:"flagged_at"`
+}
+
+// Check verifies that item totals, payment.goods_total and payment.amount agree.
+func Check(o *order.Order) []Violation {
+	var violations []Violation
+
+	goodsTotal := 0
+	for i, item := range o.Items {
+		goodsTotal += item.TotalPrice
+
+		// total_price may be rounded either way from price minus sale percent
+		exact := item.Price * (100 - item.Sale)
+		if diff := item.TotalPrice*100 - exact; diff <= -100 || diff >= 100 {
+			violations = append(violations, Violation{
+				Rule:     RuleItemTotal,
+				Field:    fmt.Sprintf("items[%d].total_price", i),
+				Expected: exact / 100,
+				Actual:   item.TotalPrice,
+			})
+		}
+	}
+
+	if o.Payment.GoodsTotal != goodsTotal {
+		violations = append(violations, Violation{
+			Rule:     RuleGoodsTotal,
+			Field:    "payment.goods_total",
+			Expected: goodsTotal,
+			Actual:   o.Payment.GoodsTotal,
+		})
+	}
+
+	amount := o.Payment.GoodsTotal + o.Payment.DeliveryCost + o.Payment.CustomFee
+	if o.Payment.Amount != amount {
+		violations = append(violations, Violation{
+			Rule:     RuleAmount,
+			Field:    "payment.amount",
+			Expected: amount,
+			Actual:   o.Payment.Amount,
+		})
+	}
+
+	return violations
+}
@@ -0,0 +1,104 @@
+package consistency
+
+import (
+	"WBL0/app/internal/order"
+	"testing"
+)
+
+// sampleOrder returns the totals of the example order from the README.
+func sampleOrder() *order.Order {
+	return &order.Order{
+		OrderUID: "b563feb7b2b84b6test",
+		Payment: order.Payment{
+			Amount:       1817,
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+			CustomFee:    0,
+		},
+		Items: []order.Item{
+			{Price: 453, Sale: 30, TotalPrice: 317},
+		},
+	}
+}
+
+func TestCheck(t *testing.T) {
+	cases := []struct {
+		name   string
+		change func(o *order.Order)
+		fields []string
+	}{
+		{"readme sample", func(o *order.Order) {}, nil},
+		{"total one unit above", func(o *order.Order) {
+			// 453 less 30% is 317.1
+			o.Items[0].TotalPrice = 318
+			o.Payment.GoodsTotal, o.Payment.Amount = 318, 1818
+		}, nil},
+		{"total one unit below", func(o *order.Order) {
+			// 457 less 30% is 319.9
+			o.Items[0] = order.Item{Price: 457, Sale: 30, TotalPrice: 319}
+			o.Payment.GoodsTotal, o.Payment.Amount = 319, 1819
+		}, nil},
+		{"total two units above", func(o *order.Order) {
+			o.Items[0].TotalPrice = 319
+			o.Payment.GoodsTotal, o.Payment.Amount = 319, 1819
+		}, []string{"items[0].total_price"}},
+		{"total two units below", func(o *order.Order) {
+			o.Items[0] = order.Item{Price: 457, Sale: 30, TotalPrice: 318}
+			o.Payment.GoodsTotal, o.Payment.Amount = 318, 1818
+		}, []string{"items[0].total_price"}},
+		{"exact total without sale", func(o *order.Order) {
+			o.Items[0] = order.Item{Price: 500, TotalPrice: 500}
+			o.Payment.GoodsTotal, o.Payment.Amount = 500, 2000
+		}, nil},
+		{"total one unit off without sale", func(o *order.Order) {
+			// Only rounding is tolerated, so a whole price must match
+			o.Items[0] = order.Item{Price: 500, TotalPrice: 501}
+			o.Payment.GoodsTotal, o.Payment.Amount = 501, 2001
+		}, []string{"items[0].total_price"}},
+		{"wrong goods total", func(o *order.Order) {
+			o.Payment.GoodsTotal = 300
+			o.Payment.Amount = 1800
+		}, []string{"payment.goods_total"}},
+		{"wrong amount", func(o *order.Order) {
+			o.Payment.Amount = 1500
+		}, []string{"payment.amount"}},
+		{"second item missing from goods total", func(o *order.Order) {
+			o.Items = append(o.Items, order.Item{Price: 100, Sale: 50, TotalPrice: 50})
+		}, []string{"payment.goods_total"}},
+	}
+	for _, c := range cases {
+		o := sampleOrder()
+		c.change(o)
+		violations := Check(o)
+		if len(violations) != len(c.fields) {
+			t.Errorf("%s: got violations %+v, want fields %v", c.name, violations, c.fields)
+			continue
+		}
+		for i, v := range violations {
+			if v.Field != c.fields[i] {
+				t.Errorf("%s: got violation %+v, want field %s", c.name, v, c.fields[i])
+			}
+		}
+	}
+}
+
+func TestCheckReportsExpectedValues(t *testing.T) {
+	o := sampleOrder()
+	o.Items[0].TotalPrice = 400
+	o.Payment.Amount = 2000
+
+	violations := Check(o)
+	want := []Violation{
+		{Rule: RuleItemTotal, Field: "items[0].total_price", Expected: 317, Actual: 400},
+		{Rule: RuleGoodsTotal, Field: "payment.goods_total", Expected: 400, Actual: 317},
+		{Rule: RuleAmount, Field: "payment.amount", Expected: 1817, Actual: 2000},
+	}
+	if len(violations) != len(want) {
+		t.Fatalf("got %+v, want %+v", violations, want)
+	}
+	for i := range want {
+		if violations[i] != want[i] {
+			t.Errorf("got %+v, want %+v", violations[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package consistency
+
+import (
+	"WBL0/app/internal/apperror"
+	"WBL0/app/internal/handler"
+	"WBL0/app/internal/response"
+	"WBL0/app/pkg/logger"
+	"errors"
+	"net/http"
+)
+
+const (
+	flaggedURL     = "/orders/flagged"
+	flaggedByIdURL = "/orders/flagged/:id"
+)
+
+type Handler struct {
+	log         logger.Logger
+	flagService Service
+}
+
+func NewHandler(log logger.Logger, flagService Service) handler.Hand {
+	return &Handler{
+		log:         log,
+		flagService: flagService,
+	}
+}
+
+func (h *Handler) Permissions() handler.Permissions {
+	return handler.Permissions{
+		handler.Route(http.MethodGet, flaggedURL):     handler.PermissionRead,
+		handler.Route(http.MethodGet, flaggedByIdURL): handler.PermissionRead,
+	}
+}
+
+func (h *Handler) Register(router handler.Router) {
+	router.HandlerFunc(http.MethodGet, flaggedURL, h.GetFlaggedOrders)
+	router.HandlerFunc(http.MethodGet, flaggedByIdURL, h.GetFlaggedOrderById)
+}
+
+func (h *Handler) GetFlaggedOrders(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("HANDLER: GET FLAGGED ORDERS")
+
+	flags, err := h.flagService.GetAll(r.Context())
+	if err != nil {
+		response.InternalError(w, r, err.Error(), "")
+		return
+	}
+
+	log.Info("GOT FLAGGED ORDERS")
+	response.JSON(w, http.StatusOK, flags)
+}
+
+func (h *Handler) GetFlaggedOrderById(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("HANDLER: GET FLAGGED ORDER BY ID")
+
+	uid, err := handler.ReadUidParam(r)
+	log.Info("Input: ", uid)
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), "")
+		return
+	}
+
+	flagged, err := h.flagService.GetById(r.Context(), uid)
+	if err != nil {
+		if errors.Is(err, apperror.ErrEmptyString) {
+			response.NotFound(w, r)
+			return
+		}
+		response.InternalError(w, r, err.Error(), "")
+		return
+	}
+
+	log.Info("GOT FLAGGED ORDER BY ID")
+	response.JSON(w, http.StatusOK, flagged)
+}
@@ -0,0 +1,120 @@
+package consistency
+
+import (
+	"WBL0/app/internal/apperror"
+	"WBL0/app/pkg/logger"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"time"
+)
+
+var _ Storage = &FlagStorage{}
+
+type FlagStorage struct {
+	log            logger.Logger
+	pool           *pgxpool.Pool
+	requestTimeout time.Duration
+}
+
+func NewStorage(storage *pgxpool.Pool, requestTimeout int) Storage {
+	return &FlagStorage{
+		log:            logger.GetLogger(),
+		pool:           storage,
+		requestTimeout: time.Duration(requestTimeout) * time.Second,
+	}
+}
+
+func (d *FlagStorage) Create(ctx context.Context, tx pgx.Tx, flagged *FlaggedOrder) (*FlaggedOrder, error) {
+	log := logger.FromContext(ctx, d.log)
+	log.Info("POSTGRES: CREATE ORDER FLAG")
+
+	ctx, cancel := context.WithTimeout(ctx, d.requestTimeout)
+	defer cancel()
+
+	violations, err := json.Marshal(flagged.Violations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode violations: %v", err)
+	}
+
+	row := tx.QueryRow(ctx,
+		`INSERT INTO order_flag (order_uid, violations)
+			 VALUES($1,$2)
+			 ON CONFLICT (order_uid) DO UPDATE SET violations = EXCLUDED.violations, flagged_at = now()
+			 RETURNING flagged_at`,
+		flagged.OrderUID, violations)
+
+	if err = row.Scan(&flagged.FlaggedAt); err != nil {
+		return nil, fmt.Errorf("failed to execute create order flag query: %v", err)
+	}
+	return flagged, nil
+}
+
+func (d *FlagStorage) FindAll(ctx context.Context) ([]*FlaggedOrder, error) {
+	log := logger.FromContext(ctx, d.log)
+	log.Info("POSTGRES: GET ORDER FLAGS")
+
+	ctx, cancel := context.WithTimeout(ctx, d.requestTimeout)
+	defer cancel()
+
+	rows, err := d.pool.Query(ctx,
+		`SELECT order_uid, violations, flagged_at FROM order_flag
+			 ORDER BY flagged_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute find order flags query: %v", err)
+	}
+	defer rows.Close()
+
+	flags := []*FlaggedOrder{}
+	for rows.Next() {
+		flagged, err := scanFlag(rows)
+		if err != nil {
+			return nil, err
+		}
+		flags = append(flags, flagged)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read order flags: %v", err)
+	}
+	return flags, nil
+}
+
+func (d *FlagStorage) FindById(ctx context.Context, uid string) (*FlaggedOrder, error) {
+	log := logger.FromContext(ctx, d.log)
+	log.Info("POSTGRES: GET ORDER FLAG BY ID")
+
+	ctx, cancel := context.WithTimeout(ctx, d.requestTimeout)
+	defer cancel()
+
+	row := d.pool.QueryRow(ctx,
+		`SELECT order_uid, violations, flagged_at FROM order_flag
+			 WHERE order_uid = $1`, uid)
+
+	flagged, err := scanFlag(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperror.ErrEmptyString
+		}
+		return nil, err
+	}
+	return flagged, nil
+}
+
+func scanFlag(row pgx.Row) (*FlaggedOrder, error) {
+	flagged := &FlaggedOrder{}
+	var violations []byte
+
+	if err := row.Scan(&flagged.OrderUID, &violations, &flagged.FlaggedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan order flag: %v", err)
+	}
+	if err := json.Unmarshal(violations, &flagged.Violations); err != nil {
+		return nil, fmt.Errorf("failed to decode violations: %v", err)
+	}
+	return flagged, nil
+}
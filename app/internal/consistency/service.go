@@ -0,0 +1,64 @@
+package consistency
+
+import (
+	"WBL0/app/internal/apperror"
+	"WBL0/app/pkg/logger"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+)
+
+type Service interface {
+	Flag(ctx context.Context, tx pgx.Tx, uid string, violations []Violation) (*FlaggedOrder, error)
+	GetAll(ctx context.Context) ([]*FlaggedOrder, error)
+	GetById(ctx context.Context, uid string) (*FlaggedOrder, error)
+}
+
+type service struct {
+	log     logger.Logger
+	storage Storage
+}
+
+func NewService(storage Storage, log logger.Logger) Service {
+	return &service{
+		log:     log,
+		storage: storage,
+	}
+}
+
+func (s *service) Flag(ctx context.Context, tx pgx.Tx, uid string, violations []Violation) (*FlaggedOrder, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: FLAG ORDER")
+
+	return s.storage.Create(ctx, tx, &FlaggedOrder{
+		OrderUID:   uid,
+		Violations: violations,
+	})
+}
+
+func (s *service) GetAll(ctx context.Context) ([]*FlaggedOrder, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: GET FLAGGED ORDERS")
+
+	flags, err := s.storage.FindAll(ctx)
+	if err != nil {
+		log.Warn("cannot find flagged orders:", err)
+		return nil, err
+	}
+	return flags, nil
+}
+
+func (s *service) GetById(ctx context.Context, uid string) (*FlaggedOrder, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: GET FLAGGED ORDER BY ID")
+
+	flagged, err := s.storage.FindById(ctx, uid)
+	if err != nil {
+		if errors.Is(err, apperror.ErrEmptyString) {
+			return nil, err
+		}
+		log.Warn("cannot find flagged order by id:", err)
+		return nil, err
+	}
+	return flagged, nil
+}
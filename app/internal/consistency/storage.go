@@ -0,0 +1,12 @@
+package consistency
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+)
+
+type Storage interface {
+	Create(ctx context.Context, tx pgx.Tx, flagged *FlaggedOrder) (*FlaggedOrder, error)
+	FindAll(ctx context.Context) ([]*FlaggedOrder, error)
+	FindById(ctx context.Context, uid string) (*FlaggedOrder, error)
+}
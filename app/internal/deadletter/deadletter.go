@@ -0,0 +1,47 @@
+package deadletter
+
+import (
+	"github.com/nats-io/nats.go"
+	"strconv"
+	"time"
+)
+
+const (
+	HeaderStage    = "Dead-Letter-Stage"
+	HeaderError    = "Dead-Letter-Error"
+	HeaderAttempts = "Dead-Letter-Attempts"
+	HeaderSubject  = "Dead-Letter-Subject"
+)
+
+type Message struct {
+	ID         int64     `json:"id"`
+	Subject    string    `json:"subject"`
+	Stage      string    `json:"stage"`
+	Error      string    `json:"error"`
+	Attempts   int       `json:"attempts"`
+	ReceivedAt time.Time `json:"received_at"`
+	Payload    string    `json:"payload"`
+}
+
+// FromHeader reads a dead letter from the headers set when it was published.
+func FromHeader(header nats.Header, data []byte, receivedAt time.Time) *Message {
+	attempts, _ := strconv.Atoi(header.Get(HeaderAttempts))
+	return &Message{
+		Subject:    header.Get(HeaderSubject),
+		Stage:      header.Get(HeaderStage),
+		Error:      header.Get(HeaderError),
+		Attempts:   attempts,
+		ReceivedAt: receivedAt,
+		Payload:    string(data),
+	}
+}
+
+// Header returns the headers a dead letter is published with.
+func (m *Message) Header() nats.Header {
+	header := nats.Header{}
+	header.Set(HeaderStage, m.Stage)
+	header.Set(HeaderError, m.Error)
+	header.Set(HeaderAttempts, strconv.Itoa(m.Attempts))
+	header.Set(HeaderSubject, m.Subject)
+	return header
+}
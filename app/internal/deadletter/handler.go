@@ -0,0 +1,78 @@
+package deadletter
+
+import (
+	"WBL0/app/internal/apperror"
+	"WBL0/app/internal/handler"
+	"WBL0/app/internal/response"
+	"WBL0/app/pkg/logger"
+	"errors"
+	"net/http"
+)
+
+const (
+	deadLettersURL = "/dead-letters"
+	redriveURL     = "/dead-letters/:id/redrive"
+)
+
+type Handler struct {
+	log               logger.Logger
+	deadLetterService Service
+}
+
+func NewHandler(log logger.Logger, deadLetterService Service) handler.Hand {
+	return &Handler{
+		log:               log,
+		deadLetterService: deadLetterService,
+	}
+}
+
+func (h *Handler) Permissions() handler.Permissions {
+	return handler.Permissions{
+		handler.Route(http.MethodGet, deadLettersURL): handler.PermissionRead,
+		handler.Route(http.MethodPost, redriveURL):    handler.PermissionAdmin,
+	}
+}
+
+func (h *Handler) Register(router handler.Router) {
+	router.HandlerFunc(http.MethodGet, deadLettersURL, h.GetDeadLetters)
+	router.HandlerFunc(http.MethodPost, redriveURL, h.RedriveDeadLetter)
+}
+
+func (h *Handler) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("HANDLER: GET DEAD LETTERS")
+
+	messages, err := h.deadLetterService.List(r.Context())
+	if err != nil {
+		response.InternalError(w, r, err.Error(), "")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, messages)
+}
+
+func (h *Handler) RedriveDeadLetter(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("HANDLER: REDRIVE DEAD LETTER")
+
+	id, err := handler.ReadIdParam64(r)
+
+	log.Info("Input: ", id)
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), "")
+		return
+	}
+
+	message, err := h.deadLetterService.Redrive(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, apperror.ErrEmptyString) {
+			response.NotFound(w, r)
+			return
+		}
+		response.InternalError(w, r, err.Error(), "")
+		return
+	}
+
+	log.Info("DEAD LETTER HAS BEEN REDRIVEN")
+	response.JSON(w, http.StatusOK, message)
+}
@@ -0,0 +1,72 @@
+package deadletter
+
+import (
+	"WBL0/app/internal/apperror"
+	"sync"
+)
+
+var _ Storage = &MemoryStorage{}
+
+// MemoryStorage keeps the last capacity dead-lettered messages in the process, so they
+// are lost on a restart. It backs core NATS mode, which does not persist messages either.
+type MemoryStorage struct {
+	mu       sync.RWMutex
+	capacity int
+	nextID   int64
+	messages []*Message
+}
+
+func NewMemoryStorage(capacity int) Storage {
+	return &MemoryStorage{
+		capacity: capacity,
+		nextID:   1,
+	}
+}
+
+func (m *MemoryStorage) Add(message *Message) (*Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	message.ID = m.nextID
+	m.nextID++
+
+	m.messages = append(m.messages, message)
+	if m.capacity > 0 && len(m.messages) > m.capacity {
+		m.messages = m.messages[len(m.messages)-m.capacity:]
+	}
+	return message, nil
+}
+
+func (m *MemoryStorage) List() ([]*Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	messages := make([]*Message, len(m.messages))
+	copy(messages, m.messages)
+	return messages, nil
+}
+
+func (m *MemoryStorage) FindById(id int64) (*Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, message := range m.messages {
+		if message.ID == id {
+			return message, nil
+		}
+	}
+	return nil, apperror.ErrEmptyString
+}
+
+func (m *MemoryStorage) Delete(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, message := range m.messages {
+		if message.ID == id {
+			m.messages = append(m.messages[:i], m.messages[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
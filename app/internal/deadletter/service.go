@@ -0,0 +1,82 @@
+package deadletter
+
+import (
+	"WBL0/app/pkg/config"
+	"WBL0/app/pkg/logger"
+	"context"
+	"fmt"
+	"github.com/nats-io/nats.go"
+	"time"
+)
+
+type Service interface {
+	Add(ctx context.Context, message *Message) (*Message, error)
+	List(ctx context.Context) ([]*Message, error)
+	Redrive(ctx context.Context, id int64) (*Message, error)
+}
+
+type service struct {
+	cfg      config.Config
+	log      logger.Logger
+	storage  Storage
+	natsConn *nats.Conn
+	js       nats.JetStreamContext
+}
+
+// NewService creates a dead-letter service that re-drives messages to the subject they were
+// consumed from, through js when it is not nil and through core NATS otherwise.
+func NewService(cfg config.Config, storage Storage, log logger.Logger, natsConn *nats.Conn, js nats.JetStreamContext) Service {
+	return &service{
+		cfg:      cfg,
+		log:      log,
+		storage:  storage,
+		natsConn: natsConn,
+		js:       js,
+	}
+}
+
+func (s *service) Add(ctx context.Context, message *Message) (*Message, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: ADD DEAD LETTER")
+
+	return s.storage.Add(message)
+}
+
+func (s *service) List(ctx context.Context) ([]*Message, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: LIST DEAD LETTERS")
+
+	return s.storage.List()
+}
+
+func (s *service) Redrive(ctx context.Context, id int64) (*Message, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: REDRIVE DEAD LETTER")
+
+	message, err := s.storage.FindById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Dead letters stored before the subject was recorded came from the orders subject
+	subject := message.Subject
+	if subject == "" {
+		subject = s.cfg.NATS.SUB
+	}
+
+	if s.js != nil {
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(s.cfg.NATS.RequestTimeout)*time.Second)
+		defer cancel()
+		_, err = s.js.Publish(subject, []byte(message.Payload), nats.Context(ctx))
+	} else {
+		err = s.natsConn.Publish(subject, []byte(message.Payload))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot republish dead letter: %v", err)
+	}
+
+	if err = s.storage.Delete(id); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
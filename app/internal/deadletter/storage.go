@@ -0,0 +1,8 @@
+package deadletter
+
+type Storage interface {
+	Add(message *Message) (*Message, error)
+	List() ([]*Message, error)
+	FindById(id int64) (*Message, error)
+	Delete(id int64) error
+}
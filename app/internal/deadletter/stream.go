@@ -0,0 +1,90 @@
+package deadletter
+
+import (
+	"WBL0/app/internal/apperror"
+	"errors"
+	"fmt"
+	"github.com/nats-io/nats.go"
+	"time"
+)
+
+var _ Storage = &StreamStorage{}
+
+// StreamStorage keeps dead letters in a JetStream stream bound to the dead-letter subject,
+// so they survive restarts and are shared by every instance. A message ID is its stream sequence.
+type StreamStorage struct {
+	js             nats.JetStreamContext
+	stream         string
+	subject        string
+	requestTimeout int
+}
+
+func NewStreamStorage(js nats.JetStreamContext, stream, subject string, requestTimeout int) Storage {
+	return &StreamStorage{
+		js:             js,
+		stream:         stream,
+		subject:        subject,
+		requestTimeout: requestTimeout,
+	}
+}
+
+func (s *StreamStorage) Add(message *Message) (*Message, error) {
+	msg := nats.NewMsg(s.subject)
+	msg.Header = message.Header()
+	msg.Data = []byte(message.Payload)
+
+	ack, err := s.js.PublishMsg(msg, nats.AckWait(time.Duration(s.requestTimeout)*time.Second))
+	if err != nil {
+		return nil, fmt.Errorf("cannot store dead letter: %v", err)
+	}
+	message.ID = int64(ack.Sequence)
+	return message, nil
+}
+
+func (s *StreamStorage) List() ([]*Message, error) {
+	info, err := s.js.StreamInfo(s.stream)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get dead-letter stream info: %v", err)
+	}
+
+	messages := make([]*Message, 0, info.State.Msgs)
+	if info.State.Msgs == 0 {
+		return messages, nil
+	}
+	for seq := info.State.FirstSeq; seq <= info.State.LastSeq; seq++ {
+		message, err := s.FindById(int64(seq))
+		if errors.Is(err, apperror.ErrEmptyString) {
+			// Re-driven dead letters leave gaps in the sequence
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func (s *StreamStorage) FindById(id int64) (*Message, error) {
+	if id <= 0 {
+		return nil, apperror.ErrEmptyString
+	}
+	raw, err := s.js.GetMsg(s.stream, uint64(id))
+	if err != nil {
+		if errors.Is(err, nats.ErrMsgNotFound) {
+			return nil, apperror.ErrEmptyString
+		}
+		return nil, fmt.Errorf("cannot get dead letter: %v", err)
+	}
+
+	message := FromHeader(raw.Header, raw.Data, raw.Time)
+	message.ID = int64(raw.Sequence)
+	return message, nil
+}
+
+func (s *StreamStorage) Delete(id int64) error {
+	if err := s.js.DeleteMsg(s.stream, uint64(id)); err != nil && !errors.Is(err, nats.ErrMsgNotFound) {
+		return fmt.Errorf("cannot delete dead letter: %v", err)
+	}
+	return nil
+}
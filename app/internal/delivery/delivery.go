@@ -1,5 +1,7 @@
 package delivery
 
+import "WBL0/app/internal/model"
+
 type Delivery struct {
 	ID      int64  `json:"id"`
 	Name    string `json:"name"`
@@ -20,3 +22,16 @@ type CreateDeliveryDTO struct {
 	Region  string `json:"region"`
 	Email   string `json:"email"`
 }
+
+func (d *Delivery) ToModel() *model.Delivery {
+	return &model.Delivery{
+		ID:      d.ID,
+		Name:    d.Name,
+		Phone:   d.Phone,
+		Zip:     d.Zip,
+		City:    d.City,
+		Address: d.Address,
+		Region:  d.Region,
+		Email:   d.Email,
+	}
+}
@@ -4,10 +4,10 @@ import (
 	"WBL0/app/internal/apperror"
 	"WBL0/app/internal/cache"
 	"WBL0/app/internal/handler"
+	"WBL0/app/internal/metrics"
 	"WBL0/app/internal/response"
 	"WBL0/app/pkg/logger"
 	"errors"
-	"github.com/julienschmidt/httprouter"
 	"net/http"
 )
 
@@ -29,24 +29,32 @@ func NewHandler(log logger.Logger, deliveryService Service, cache *cache.Cache)
 	}
 }
 
-func (h *Handler) Register(router *httprouter.Router) {
+func (h *Handler) Permissions() handler.Permissions {
+	return handler.Permissions{
+		handler.Route(http.MethodGet, deliveryURL): handler.PermissionRead,
+	}
+}
+
+func (h *Handler) Register(router handler.Router) {
 	router.HandlerFunc(http.MethodGet, deliveryURL, h.GetDeliveryById)
 }
 
 func (h *Handler) GetDeliveryById(w http.ResponseWriter, r *http.Request) {
-	h.log.Infof("HANDLER: GET DELIVERY BY ID")
+	log := logger.FromContext(r.Context(), h.log)
+	log.Infof("HANDLER: GET DELIVERY BY ID")
 
 	id, err := handler.ReadIdParam64(r)
 
-	h.log.Info("Input: ", id)
+	log.Info("Input: ", id)
 	if err != nil {
-		response.BadRequest(w, err.Error(), "")
+		response.BadRequest(w, r, err.Error(), "")
 		return
 	}
 
-	cacheDelivery, ok := h.cache.Deliveries[id]
+	cacheDelivery, ok := h.cache.Deliveries().Get(id)
+	metrics.CacheLookup(metrics.EntityDelivery, ok)
 	if ok {
-		h.log.Info("GOT DELIVERY FROM CACHE BY ID")
+		log.Info("GOT DELIVERY FROM CACHE BY ID")
 		response.JSON(w, http.StatusOK, cacheDelivery)
 		return
 	}
@@ -54,13 +62,15 @@ func (h *Handler) GetDeliveryById(w http.ResponseWriter, r *http.Request) {
 	delivery, err := h.deliveryService.GetById(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, apperror.ErrEmptyString) {
-			response.NotFound(w)
+			response.NotFound(w, r)
 			return
 		}
-		response.InternalError(w, err.Error(), "")
+		response.InternalError(w, r, err.Error(), "")
 		return
 	}
 
-	h.log.Info("GOT DELIVERY BY ID")
+	h.cache.Deliveries().Add(delivery.ID, delivery.ToModel())
+
+	log.Info("GOT DELIVERY BY ID")
 	response.JSON(w, http.StatusOK, delivery)
 }
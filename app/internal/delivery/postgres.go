@@ -3,12 +3,12 @@ package delivery
 import (
 	"WBL0/app/internal/apperror"
 	"WBL0/app/internal/cache"
-	"WBL0/app/internal/model"
 	"WBL0/app/pkg/logger"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"time"
 )
 
@@ -16,27 +16,26 @@ var _ Storage = &DeliveryStorage{}
 
 type DeliveryStorage struct {
 	log            logger.Logger
-	conn           *pgx.Conn
+	pool           *pgxpool.Pool
 	requestTimeout time.Duration
-	cache          *cache.Cache
 }
 
-func NewStorage(storage *pgx.Conn, requestTimeout int, cache *cache.Cache) Storage {
+func NewStorage(storage *pgxpool.Pool, requestTimeout int) Storage {
 	return &DeliveryStorage{
 		log:            logger.GetLogger(),
-		conn:           storage,
+		pool:           storage,
 		requestTimeout: time.Duration(requestTimeout) * time.Second,
-		cache:          cache,
 	}
 }
 
-func (d *DeliveryStorage) Create(delivery *Delivery) (*Delivery, error) {
-	d.log.Info("POSTGRES: CREATE DELIVERY")
+func (d *DeliveryStorage) Create(ctx context.Context, tx pgx.Tx, delivery *Delivery) (*Delivery, error) {
+	log := logger.FromContext(ctx, d.log)
+	log.Info("POSTGRES: CREATE DELIVERY")
 
-	ctx, cancel := context.WithTimeout(context.Background(), d.requestTimeout)
+	ctx, cancel := context.WithTimeout(ctx, d.requestTimeout)
 	defer cancel()
 
-	row := d.conn.QueryRow(ctx,
+	row := tx.QueryRow(ctx,
 		`INSERT INTO Delivery (name, phone, zip, city, address, region, email)
 			 VALUES($1,$2,$3,$4,$5,$6,$7) 
 			 RETURNING id`,
@@ -47,35 +46,17 @@ func (d *DeliveryStorage) Create(delivery *Delivery) (*Delivery, error) {
 		err = fmt.Errorf("failed to execute create delivery query: %v", err)
 		return nil, err
 	}
-
-	modelDelivery := &model.Delivery{
-		ID:      delivery.ID,
-		Name:    delivery.Name,
-		Phone:   delivery.Phone,
-		Zip:     delivery.Zip,
-		City:    delivery.City,
-		Address: delivery.Address,
-		Region:  delivery.Region,
-		Email:   delivery.Email,
-	}
-
-	d.cache.Deliveries[delivery.ID] = modelDelivery
-
-	fmt.Println("\n\nCache after delivery creation:")
-	for key, value := range d.cache.Deliveries {
-		fmt.Printf("Key: %d, Value: %+v\n", key, value)
-	}
-
 	return delivery, nil
 }
 
-func (d *DeliveryStorage) FindById(id int64) (*Delivery, error) {
-	d.log.Info("POSTGRES: GET DELIVERY BY ID")
+func (d *DeliveryStorage) FindById(ctx context.Context, id int64) (*Delivery, error) {
+	log := logger.FromContext(ctx, d.log)
+	log.Info("POSTGRES: GET DELIVERY BY ID")
 
-	ctx, cancel := context.WithTimeout(context.Background(), d.requestTimeout)
+	ctx, cancel := context.WithTimeout(ctx, d.requestTimeout)
 	defer cancel()
 
-	row := d.conn.QueryRow(ctx,
+	row := d.pool.QueryRow(ctx,
 		`SELECT * FROM Delivery
 			 WHERE id = $1`, id)
 	delivery := &Delivery{}
@@ -93,9 +74,9 @@ func (d *DeliveryStorage) FindById(id int64) (*Delivery, error) {
 	return delivery, nil
 }
 
-func CacheForDelivery(dbConn *pgx.Conn, cache *cache.Cache) error {
+func CacheForDelivery(dbPool *pgxpool.Pool, cache *cache.Cache) error {
 
-	rows, err := dbConn.Query(context.Background(), `SELECT * FROM Delivery`)
+	rows, err := dbPool.Query(context.Background(), `SELECT * FROM Delivery`)
 	if err != nil {
 		return err
 	}
@@ -109,17 +90,7 @@ func CacheForDelivery(dbConn *pgx.Conn, cache *cache.Cache) error {
 		if err != nil {
 			return err
 		}
-		modelDelivery := &model.Delivery{
-			ID:      delivery.ID,
-			Name:    delivery.Name,
-			Phone:   delivery.Phone,
-			Zip:     delivery.Zip,
-			City:    delivery.City,
-			Address: delivery.Address,
-			Region:  delivery.Region,
-			Email:   delivery.Email,
-		}
-		cache.Deliveries[delivery.ID] = modelDelivery
+		cache.Deliveries().Set(delivery.ID, delivery.ToModel())
 	}
-	return nil
+	return rows.Err()
 }
@@ -5,10 +5,11 @@ import (
 	"WBL0/app/pkg/logger"
 	"context"
 	"errors"
+	"github.com/jackc/pgx/v4"
 )
 
 type Service interface {
-	Create(ctx context.Context, delivery *CreateDeliveryDTO) (*Delivery, error)
+	Create(ctx context.Context, tx pgx.Tx, delivery *CreateDeliveryDTO) (*Delivery, error)
 	GetById(ctx context.Context, id int64) (*Delivery, error)
 }
 
@@ -23,8 +24,9 @@ func NewService(storage Storage, log logger.Logger) Service {
 		storage: storage,
 	}
 }
-func (s *service) Create(ctx context.Context, input *CreateDeliveryDTO) (*Delivery, error) {
-	s.log.Info("SERVICE: CREATE DELIVERY")
+func (s *service) Create(ctx context.Context, tx pgx.Tx, input *CreateDeliveryDTO) (*Delivery, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: CREATE DELIVERY")
 
 	d := Delivery{
 		Name:    input.Name,
@@ -36,7 +38,7 @@ func (s *service) Create(ctx context.Context, input *CreateDeliveryDTO) (*Delive
 		Email:   input.Email,
 	}
 
-	delivery, err := s.storage.Create(&d)
+	delivery, err := s.storage.Create(ctx, tx, &d)
 	if err != nil {
 		return nil, err
 	}
@@ -44,14 +46,15 @@ func (s *service) Create(ctx context.Context, input *CreateDeliveryDTO) (*Delive
 }
 
 func (s *service) GetById(ctx context.Context, id int64) (*Delivery, error) {
-	s.log.Info("SERVICE: GET DELIVERY BY ID")
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: GET DELIVERY BY ID")
 
-	delivery, err := s.storage.FindById(id)
+	delivery, err := s.storage.FindById(ctx, id)
 	if err != nil {
 		if errors.Is(err, apperror.ErrEmptyString) {
 			return nil, err
 		}
-		s.log.Warn("cannot find delivery by id:", err)
+		log.Warn("cannot find delivery by id:", err)
 		return nil, err
 	}
 	return delivery, nil
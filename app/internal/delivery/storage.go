@@ -1,6 +1,11 @@
 package delivery
 
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+)
+
 type Storage interface {
-	Create(delivery *Delivery) (*Delivery, error)
-	FindById(id int64) (*Delivery, error)
+	Create(ctx context.Context, tx pgx.Tx, delivery *Delivery) (*Delivery, error)
+	FindById(ctx context.Context, id int64) (*Delivery, error)
 }
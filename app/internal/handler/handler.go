@@ -8,7 +8,30 @@ import (
 )
 
 type Hand interface {
-	Register(router *httprouter.Router)
+	Register(router Router)
+	Permissions() Permissions
+}
+
+type Permission string
+
+const (
+	PermissionPublic  Permission = "public"
+	PermissionRead    Permission = "read"
+	PermissionPublish Permission = "publish"
+	PermissionAdmin   Permission = "admin"
+)
+
+// Permissions map a route, see Route, to the permission a caller needs for it.
+type Permissions map[string]Permission
+
+func Route(method, path string) string {
+	return method + " " + path
+}
+
+// Router is the part of *httprouter.Router that handlers register their routes on,
+// so that middleware can wrap each route knowing its method and path.
+type Router interface {
+	HandlerFunc(method, path string, handler http.HandlerFunc)
 }
 
 func ReadIdParam64(r *http.Request) (int64, error) {
@@ -19,3 +42,21 @@ func ReadIdParam64(r *http.Request) (int64, error) {
 	}
 	return id, nil
 }
+
+func ReadUidParam(r *http.Request) (string, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	uid := params.ByName("id")
+	if uid == "" {
+		return "", fmt.Errorf("empty uid")
+	}
+	return uid, nil
+}
+
+func ReadStringParam(r *http.Request, name string) (string, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	value := params.ByName(name)
+	if value == "" {
+		return "", fmt.Errorf("empty %s", name)
+	}
+	return value, nil
+}
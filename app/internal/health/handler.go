@@ -0,0 +1,50 @@
+package health
+
+import (
+	"WBL0/app/internal/handler"
+	"WBL0/app/internal/response"
+	"WBL0/app/pkg/logger"
+	"net/http"
+)
+
+const (
+	liveURL  = "/healthz"
+	readyURL = "/readyz"
+)
+
+type Handler struct {
+	log           logger.Logger
+	healthService Service
+}
+
+func NewHandler(log logger.Logger, healthService Service) handler.Hand {
+	return &Handler{
+		log:           log,
+		healthService: healthService,
+	}
+}
+
+func (h *Handler) Permissions() handler.Permissions {
+	return handler.Permissions{
+		handler.Route(http.MethodGet, liveURL):  handler.PermissionPublic,
+		handler.Route(http.MethodGet, readyURL): handler.PermissionPublic,
+	}
+}
+
+func (h *Handler) Register(router handler.Router) {
+	router.HandlerFunc(http.MethodGet, liveURL, h.Live)
+	router.HandlerFunc(http.MethodGet, readyURL, h.Ready)
+}
+
+func (h *Handler) Live(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, h.healthService.Live(r.Context()))
+}
+
+func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
+	report := h.healthService.Ready(r.Context())
+	if !report.Ready() {
+		response.JSON(w, http.StatusServiceUnavailable, report)
+		return
+	}
+	response.JSON(w, http.StatusOK, report)
+}
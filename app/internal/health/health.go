@@ -0,0 +1,22 @@
+package health
+
+const (
+	StatusOK      = "ok"
+	StatusFailing = "failing"
+	StatusLoading = "loading"
+)
+
+type Check struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string           `json:"status"`
+	Checks map[string]Check `json:"checks,omitempty"`
+}
+
+func (r *Report) Ready() bool {
+	return r.Status == StatusOK
+}
@@ -0,0 +1,99 @@
+package health
+
+import (
+	"WBL0/app/internal/cache"
+	"WBL0/app/pkg/logger"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/nats-io/nats.go"
+	"time"
+)
+
+type Service interface {
+	Live(ctx context.Context) *Report
+	Ready(ctx context.Context) *Report
+}
+
+type service struct {
+	log            logger.Logger
+	pool           *pgxpool.Pool
+	natsConn       *nats.Conn
+	cache          *cache.Cache
+	requestTimeout time.Duration
+}
+
+func NewService(pool *pgxpool.Pool, natsConn *nats.Conn, cache *cache.Cache, log logger.Logger, requestTimeout int) Service {
+	return &service{
+		log:            log,
+		pool:           pool,
+		natsConn:       natsConn,
+		cache:          cache,
+		requestTimeout: time.Duration(requestTimeout) * time.Second,
+	}
+}
+
+// Live only says the process is up and serving HTTP; it checks no dependencies.
+func (s *service) Live(ctx context.Context) *Report {
+	return &Report{Status: StatusOK}
+}
+
+func (s *service) Ready(ctx context.Context) *Report {
+	log := logger.FromContext(ctx, s.log)
+	report := &Report{
+		Status: StatusOK,
+		Checks: map[string]Check{
+			"database": s.check(func() (string, error) { return s.checkDatabase(ctx) }),
+			"nats":     s.check(s.checkNATS),
+			"cache":    s.check(s.checkCache),
+		},
+	}
+	for name, c := range report.Checks {
+		if c.Status != StatusOK {
+			log.Warnf("readiness check %s is %s: %s", name, c.Status, c.Error)
+			report.Status = StatusFailing
+		}
+	}
+	return report
+}
+
+func (s *service) check(fn func() (string, error)) Check {
+	start := time.Now()
+	status, err := fn()
+	c := Check{
+		Status:    status,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		c.Error = err.Error()
+	}
+	return c
+}
+
+func (s *service) checkDatabase(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.requestTimeout)
+	defer cancel()
+
+	if err := s.pool.Ping(ctx); err != nil {
+		return StatusFailing, fmt.Errorf("failed to ping database: %v", err)
+	}
+	return StatusOK, nil
+}
+
+func (s *service) checkNATS() (string, error) {
+	if status := s.natsConn.Status(); status != nats.CONNECTED {
+		return StatusFailing, fmt.Errorf("NATS connection is %s", status)
+	}
+	return StatusOK, nil
+}
+
+func (s *service) checkCache() (string, error) {
+	done, err := s.cache.LoadState()
+	if !done {
+		return StatusLoading, fmt.Errorf("cache warm-up is in progress")
+	}
+	if err != nil {
+		return StatusFailing, fmt.Errorf("cache warm-up failed: %v", err)
+	}
+	return StatusOK, nil
+}
@@ -0,0 +1,45 @@
+package ingest
+
+import "errors"
+
+const (
+	StageDecode      = "decode"
+	StageValidate    = "validate"
+	StageConsistency = "consistency"
+	StageDelivery    = "delivery"
+	StagePayment     = "payment"
+	StageItems       = "items"
+	StageOrder       = "order"
+	StageFlag        = "flag"
+	StageCommit      = "commit"
+	StagePanic       = "panic"
+)
+
+// Terminal reports whether redelivering a message that failed at stage can never succeed.
+// A message that made the ingestion panic is not retried either, so it cannot keep crashing it.
+func Terminal(stage string) bool {
+	return stage == StageDecode || stage == StageValidate || stage == StageConsistency || stage == StagePanic
+}
+
+// StageError tells at which step of the ingestion an order failed.
+type StageError struct {
+	Stage string
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return e.Stage + ": " + e.Err.Error()
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// Stage returns the failed stage of err, or StageCommit if err is not a StageError.
+func Stage(err error) string {
+	var stageErr *StageError
+	if errors.As(err, &stageErr) {
+		return stageErr.Stage
+	}
+	return StageCommit
+}
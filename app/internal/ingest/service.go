@@ -0,0 +1,263 @@
+package ingest
+
+import (
+	"WBL0/app/internal/apperror"
+	"WBL0/app/internal/cache"
+	"WBL0/app/internal/consistency"
+	"WBL0/app/internal/delivery"
+	"WBL0/app/internal/item"
+	"WBL0/app/internal/metrics"
+	"WBL0/app/internal/order"
+	"WBL0/app/internal/payment"
+	"WBL0/app/pkg/config"
+	"WBL0/app/pkg/logger"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"reflect"
+)
+
+const (
+	DuplicateSkip   = "skip"
+	DuplicateUpsert = "upsert"
+)
+
+// Service writes an incoming order with all of its parts as one unit of work.
+type Service interface {
+	Ingest(ctx context.Context, input *order.Order) (*order.CreateOrderDTO, error)
+}
+
+type service struct {
+	cfg             config.Config
+	log             logger.Logger
+	pool            *pgxpool.Pool
+	cache           *cache.Cache
+	deliveryService delivery.Service
+	paymentService  payment.Service
+	itemService     item.Service
+	orderService    order.Service
+	flagService     consistency.Service
+	sampler         *logger.Sampler
+}
+
+func NewService(cfg config.Config, pool *pgxpool.Pool, cache *cache.Cache, log logger.Logger, deliveryService delivery.Service, paymentService payment.Service, itemService item.Service, orderService order.Service, flagService consistency.Service) Service {
+	return &service{
+		cfg:             cfg,
+		log:             log,
+		pool:            pool,
+		cache:           cache,
+		deliveryService: deliveryService,
+		paymentService:  paymentService,
+		itemService:     itemService,
+		orderService:    orderService,
+		flagService:     flagService,
+		sampler:         logger.NewSampler(cfg.Log.SampleEvery),
+	}
+}
+
+type created struct {
+	delivery *delivery.Delivery
+	payment  *payment.Payment
+	items    []*item.Item
+	order    *order.CreateOrderDTO
+	replaced *order.CreateOrderDTO
+	skipped  bool
+}
+
+func (s *service) Ingest(ctx context.Context, input *order.Order) (*order.CreateOrderDTO, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: INGEST ORDER")
+
+	if err := input.Validate(); err != nil {
+		return nil, &StageError{Stage: StageValidate, Err: err}
+	}
+
+	var violations []consistency.Violation
+	if s.cfg.Ingest.ConsistencyMode != consistency.ModeIgnore {
+		violations = consistency.Check(input)
+	}
+	if len(violations) > 0 && s.cfg.Ingest.ConsistencyMode == consistency.ModeReject {
+		return nil, &StageError{Stage: StageConsistency, Err: fmt.Errorf("inconsistent totals: %+v", violations)}
+	}
+
+	var c created
+	err := s.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var err error
+		c, err = s.ingest(ctx, tx, input, violations)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if c.skipped {
+		return c.order, nil
+	}
+
+	// The cache is only touched once the transaction is committed,
+	// so a failed message leaves neither orphan rows nor cache entries.
+	s.updateCache(&c)
+	return c.order, nil
+}
+
+// ingest checks for an already stored order_uid before any child row is written.
+func (s *service) ingest(ctx context.Context, tx pgx.Tx, input *order.Order, violations []consistency.Violation) (created, error) {
+	log := logger.FromContext(ctx, s.log)
+	existing, err := s.orderService.LockById(ctx, tx, input.OrderUID)
+	if err != nil && !errors.Is(err, apperror.ErrEmptyString) {
+		return created{}, &StageError{Stage: StageOrder, Err: err}
+	}
+	if existing == nil {
+		return s.create(ctx, tx, input, violations)
+	}
+
+	metrics.MessageDuplicated(s.cfg.Ingest.DuplicatePolicy)
+
+	if s.cfg.Ingest.DuplicatePolicy == DuplicateUpsert {
+		stored, err := s.orderService.GetFullByIdTx(ctx, tx, input.OrderUID)
+		if err != nil {
+			return created{}, &StageError{Stage: StageOrder, Err: err}
+		}
+		if !sameOrder(stored, input) {
+			log.Warnf("duplicate order %s with changed content, replacing it", input.OrderUID)
+			if err = s.orderService.Delete(ctx, tx, input.OrderUID); err != nil {
+				return created{}, &StageError{Stage: StageOrder, Err: err}
+			}
+			c, err := s.create(ctx, tx, input, violations)
+			c.replaced = existing
+			return c, err
+		}
+	}
+
+	log.Warnf("duplicate order %s skipped", input.OrderUID)
+	return created{order: existing, skipped: true}, nil
+}
+
+func sameOrder(stored, input *order.Order) bool {
+	a, b := *stored, *input
+	if len(a.Items) == 0 && len(b.Items) == 0 {
+		a.Items, b.Items = nil, nil
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func (s *service) create(ctx context.Context, tx pgx.Tx, input *order.Order, violations []consistency.Violation) (created, error) {
+	log := logger.FromContext(ctx, s.log)
+	var c created
+
+	deliveryDTO := delivery.CreateDeliveryDTO{
+		Name:    input.Delivery.Name,
+		Phone:   input.Delivery.Phone,
+		Zip:     input.Delivery.Zip,
+		City:    input.Delivery.City,
+		Address: input.Delivery.Address,
+		Region:  input.Delivery.Region,
+		Email:   input.Delivery.Email,
+	}
+	d, err := s.deliveryService.Create(ctx, tx, &deliveryDTO)
+	if err != nil {
+		return c, &StageError{Stage: StageDelivery, Err: err}
+	}
+	log.Info("Input Delivery ID: ", d.ID)
+	c.delivery = d
+
+	paymentDTO := payment.CreatePaymentDTO{
+		Transaction:  input.Payment.Transaction,
+		RequestID:    input.Payment.RequestID,
+		Currency:     input.Payment.Currency,
+		Provider:     input.Payment.Provider,
+		Amount:       input.Payment.Amount,
+		PaymentDt:    input.Payment.PaymentDt,
+		Bank:         input.Payment.Bank,
+		DeliveryCost: input.Payment.DeliveryCost,
+		GoodsTotal:   input.Payment.GoodsTotal,
+		CustomFee:    input.Payment.CustomFee,
+	}
+	p, err := s.paymentService.Create(ctx, tx, &paymentDTO)
+	if err != nil {
+		return c, &StageError{Stage: StagePayment, Err: err}
+	}
+	log.Info("Input Payment ID: ", p.ID)
+	c.payment = p
+
+	itemDTOs := make([]*item.CreateItemDTO, 0, len(input.Items))
+	for _, i := range input.Items {
+		itemDTOs = append(itemDTOs, &item.CreateItemDTO{
+			ChrtID:      i.ChrtID,
+			TrackNumber: i.TrackNumber,
+			Price:       i.Price,
+			RID:         i.RID,
+			Name:        i.Name,
+			Sale:        i.Sale,
+			Size:        i.Size,
+			TotalPrice:  i.TotalPrice,
+			NmID:        i.NmID,
+			Brand:       i.Brand,
+			Status:      i.Status,
+		})
+	}
+	items, err := s.itemService.Create(ctx, tx, itemDTOs)
+	if err != nil {
+		return c, &StageError{Stage: StageItems, Err: err}
+	}
+	itemIDs := []int64{}
+	for _, i := range items {
+		itemIDs = append(itemIDs, i.ID)
+		log.Info("Input Items ID: ", i.ID)
+	}
+	c.items = items
+
+	orderDTO := order.CreateOrderDTO{
+		OrderUID:          input.OrderUID,
+		TrackNumber:       input.TrackNumber,
+		Entry:             input.Entry,
+		Delivery:          d.ID,
+		Payment:           p.ID,
+		Items:             itemIDs,
+		Locale:            input.Locale,
+		InternalSignature: input.InternalSignature,
+		CustomerID:        input.CustomerID,
+		DeliveryService:   input.DeliveryService,
+		ShardKey:          input.ShardKey,
+		SMID:              input.SMID,
+		DateCreated:       input.DateCreated,
+		OofShard:          input.OofShard,
+	}
+	o, err := s.orderService.Create(ctx, tx, &orderDTO)
+	if err != nil {
+		return c, &StageError{Stage: StageOrder, Err: err}
+	}
+	log.Info("Input Order ID: ", o.OrderUID)
+	c.order = o
+
+	if len(violations) > 0 {
+		if _, err = s.flagService.Flag(ctx, tx, o.OrderUID, violations); err != nil {
+			return c, &StageError{Stage: StageFlag, Err: err}
+		}
+		log.Warnf("order %s flagged with %d consistency violations", o.OrderUID, len(violations))
+	}
+
+	return c, nil
+}
+
+func (s *service) updateCache(c *created) {
+	if c.replaced != nil {
+		s.cache.Deliveries().Delete(c.replaced.Delivery)
+		s.cache.Payments().Delete(c.replaced.Payment)
+		for _, id := range c.replaced.Items {
+			s.cache.Items().Delete(id)
+		}
+	}
+
+	s.cache.Deliveries().Set(c.delivery.ID, c.delivery.ToModel())
+	s.cache.Payments().Set(c.payment.ID, c.payment.ToModel())
+	for _, i := range c.items {
+		s.cache.Items().Set(i.ID, i.ToModel())
+	}
+	s.cache.Orders().Set(c.order.OrderUID, c.order.ToModel())
+
+	if s.sampler.Sample() {
+		s.log.Debugf("cached order %s with delivery %d, payment %d and items %v", c.order.OrderUID, c.delivery.ID, c.payment.ID, c.order.Items)
+	}
+}
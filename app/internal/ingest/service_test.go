@@ -0,0 +1,306 @@
+package ingest
+
+import (
+	"WBL0/app/internal/cache"
+	"WBL0/app/internal/consistency"
+	"WBL0/app/internal/delivery"
+	"WBL0/app/internal/item"
+	"WBL0/app/internal/order"
+	"WBL0/app/internal/payment"
+	"WBL0/app/pkg/config"
+	"WBL0/app/pkg/logger"
+	postgres "WBL0/app/pkg/storage"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"os"
+	"testing"
+	"time"
+)
+
+// testPool connects to the database in TEST_DATABASE_DSN and migrates a scratch schema, which is
+// dropped once the test is done. Tests that need Postgres are skipped without the variable.
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	ctx := context.Background()
+
+	admin, err := pgxpool.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(admin.Close)
+	schema := fmt.Sprintf("ingest_test_%d", time.Now().UnixNano())
+	if _, err = admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+	})
+
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.ConnectConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	if _, err = postgres.MigrateUp(ctx, pool); err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+// testService wires the ingestion to Postgres the way the server does, with orderService
+// wrapping the order service when it is not nil.
+func testService(pool *pgxpool.Pool, policy string, orderService func(order.Service) order.Service) (Service, *cache.Cache) {
+	var cfg config.Config
+	cfg.Ingest.DuplicatePolicy = policy
+	cfg.Ingest.ConsistencyMode = consistency.ModeFlag
+
+	log := logger.GetLogger()
+	orders := order.NewService(order.NewStorage(pool, 5), log)
+	if orderService != nil {
+		orders = orderService(orders)
+	}
+	c := cache.NewCache()
+	return NewService(cfg, pool, c, log,
+		delivery.NewService(delivery.NewStorage(pool, 5), log),
+		payment.NewService(payment.NewStorage(pool, 5), log),
+		item.NewService(item.NewStorage(pool, 5), log),
+		orders,
+		consistency.NewService(consistency.NewStorage(pool, 5), log),
+	), c
+}
+
+// sampleOrder returns the example order from the README.
+func sampleOrder() *order.Order {
+	return &order.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: order.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: order.Payment{
+			Transaction:  "b563feb7b2b84b6test",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []order.Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			RID:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SMID:            99,
+		DateCreated:     "2021-11-26T06:22:19Z",
+		OofShard:        "1",
+	}
+}
+
+// countRows returns the number of rows of every table an order is written to.
+func countRows(t *testing.T, pool *pgxpool.Pool) map[string]int {
+	t.Helper()
+
+	counts := map[string]int{}
+	for _, table := range []string{"Delivery", "Payment", "Item", "order_items", `"order"`} {
+		var n int
+		if err := pool.QueryRow(context.Background(), "SELECT count(*) FROM "+table).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		counts[table] = n
+	}
+	return counts
+}
+
+func assertRows(t *testing.T, pool *pgxpool.Pool, want int) {
+	t.Helper()
+
+	for table, n := range countRows(t, pool) {
+		if n != want {
+			t.Errorf("expected %d rows in %s, got %d", want, table, n)
+		}
+	}
+}
+
+// rowExists reports whether table has a row with id.
+func rowExists(t *testing.T, pool *pgxpool.Pool, table string, id int64) bool {
+	t.Helper()
+
+	var exists bool
+	if err := pool.QueryRow(context.Background(), "SELECT EXISTS (SELECT 1 FROM "+table+" WHERE id = $1)", id).Scan(&exists); err != nil {
+		t.Fatal(err)
+	}
+	return exists
+}
+
+// duplicates reads wbl0_nats_duplicates_total for policy from the default registry.
+func duplicates(t *testing.T, policy string) float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "wbl0_nats_duplicates_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "policy" && label.GetValue() == policy {
+					return metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestIngestSkipsRedeliveredOrder(t *testing.T) {
+	for _, policy := range []string{DuplicateSkip, DuplicateUpsert} {
+		t.Run(policy, func(t *testing.T) {
+			pool := testPool(t)
+			s, c := testService(pool, policy, nil)
+			ctx := context.Background()
+
+			first, err := s.Ingest(ctx, sampleOrder())
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := duplicates(t, policy)
+
+			again, err := s.Ingest(ctx, sampleOrder())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.Delivery != first.Delivery || again.Payment != first.Payment || len(again.Items) != 1 || again.Items[0] != first.Items[0] {
+				t.Fatalf("expected the stored order %+v, got %+v", first, again)
+			}
+			assertRows(t, pool, 1)
+			if n := duplicates(t, policy) - before; n != 1 {
+				t.Fatalf("expected the duplicate to be counted once, got %v", n)
+			}
+			if c.Deliveries().Len() != 1 || c.Payments().Len() != 1 || c.Items().Len() != 1 || c.Orders().Len() != 1 {
+				t.Fatal("expected the cache to hold the order parts once")
+			}
+		})
+	}
+}
+
+func TestIngestUpsertReplacesChangedOrder(t *testing.T) {
+	pool := testPool(t)
+	s, c := testService(pool, DuplicateUpsert, nil)
+	ctx := context.Background()
+
+	old, err := s.Ingest(ctx, sampleOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := sampleOrder()
+	changed.Delivery.City = "Haifa"
+	changed.TrackNumber = "WBILMTESTTRACK2"
+	changed.Items[0].TrackNumber = "WBILMTESTTRACK2"
+	replaced, err := s.Ingest(ctx, changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced.Delivery == old.Delivery || replaced.Payment == old.Payment || replaced.Items[0] == old.Items[0] {
+		t.Fatalf("expected new parts, got %+v for %+v", replaced, old)
+	}
+
+	assertRows(t, pool, 1)
+	for table, ids := range map[string][2]int64{
+		"Delivery": {old.Delivery, replaced.Delivery},
+		"Payment":  {old.Payment, replaced.Payment},
+		"Item":     {old.Items[0], replaced.Items[0]},
+	} {
+		if rowExists(t, pool, table, ids[0]) || !rowExists(t, pool, table, ids[1]) {
+			t.Errorf("expected %s %d to be replaced by %d", table, ids[0], ids[1])
+		}
+	}
+
+	if _, ok := c.Deliveries().Get(old.Delivery); ok {
+		t.Error("expected the old delivery to be dropped from the cache")
+	}
+	if _, ok := c.Payments().Get(old.Payment); ok {
+		t.Error("expected the old payment to be dropped from the cache")
+	}
+	if _, ok := c.Items().Get(old.Items[0]); ok {
+		t.Error("expected the old item to be dropped from the cache")
+	}
+	if d, ok := c.Deliveries().Get(replaced.Delivery); !ok || d.City != "Haifa" {
+		t.Errorf("expected the new delivery to be cached, got %+v", d)
+	}
+	if o, ok := c.Orders().Get(changed.OrderUID); !ok || o.Delivery != replaced.Delivery {
+		t.Errorf("expected the cached order to refer to the new parts, got %+v", o)
+	}
+
+	// The index follows the replaced order and item to their new track
+	if got := c.OrdersByTrack("WBILMTESTTRACK2"); len(got) != 1 || got[0].OrderUID != changed.OrderUID {
+		t.Errorf("expected the order under the new track, got %+v", got)
+	}
+	if got := c.OrdersByTrack("WBILMTESTTRACK"); len(got) != 0 {
+		t.Errorf("expected nothing under the old track, got %+v", got)
+	}
+}
+
+// failingOrders fails every order insert, after the delivery, payment and items of the order are written.
+type failingOrders struct {
+	order.Service
+}
+
+func (s failingOrders) Create(ctx context.Context, tx pgx.Tx, o *order.CreateOrderDTO) (*order.CreateOrderDTO, error) {
+	return nil, errors.New("order insert failed")
+}
+
+func TestIngestRollsBackFailedOrder(t *testing.T) {
+	pool := testPool(t)
+	s, c := testService(pool, DuplicateSkip, func(orders order.Service) order.Service {
+		return failingOrders{orders}
+	})
+
+	_, err := s.Ingest(context.Background(), sampleOrder())
+	if Stage(err) != StageOrder {
+		t.Fatalf("expected the ingestion to fail at stage %q, got %v", StageOrder, err)
+	}
+	assertRows(t, pool, 0)
+	if c.Deliveries().Len() != 0 || c.Payments().Len() != 0 || c.Items().Len() != 0 || c.Orders().Len() != 0 {
+		t.Fatal("expected nothing to be cached for a failed order")
+	}
+}
@@ -4,10 +4,10 @@ import (
 	"WBL0/app/internal/apperror"
 	"WBL0/app/internal/cache"
 	"WBL0/app/internal/handler"
+	"WBL0/app/internal/metrics"
 	"WBL0/app/internal/response"
 	"WBL0/app/pkg/logger"
 	"errors"
-	"github.com/julienschmidt/httprouter"
 	"net/http"
 )
 
@@ -29,24 +29,32 @@ func NewHandler(log logger.Logger, itemService Service, cache *cache.Cache) hand
 	}
 }
 
-func (h *Handler) Register(router *httprouter.Router) {
+func (h *Handler) Permissions() handler.Permissions {
+	return handler.Permissions{
+		handler.Route(http.MethodGet, itemURL): handler.PermissionRead,
+	}
+}
+
+func (h *Handler) Register(router handler.Router) {
 	router.HandlerFunc(http.MethodGet, itemURL, h.GetItemById)
 }
 
 func (h *Handler) GetItemById(w http.ResponseWriter, r *http.Request) {
-	h.log.Info("HANDLER: GET ITEM BY ID")
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("HANDLER: GET ITEM BY ID")
 
 	id, err := handler.ReadIdParam64(r)
 
-	h.log.Info("Input: ", id)
+	log.Info("Input: ", id)
 	if err != nil {
-		response.BadRequest(w, err.Error(), "")
+		response.BadRequest(w, r, err.Error(), "")
 		return
 	}
 
-	cacheItem, ok := h.cache.Items[id]
+	cacheItem, ok := h.cache.Items().Get(id)
+	metrics.CacheLookup(metrics.EntityItem, ok)
 	if ok {
-		h.log.Info("GOT ITEM FROM CACHE BY ID")
+		log.Info("GOT ITEM FROM CACHE BY ID")
 		response.JSON(w, http.StatusOK, cacheItem)
 		return
 	}
@@ -54,13 +62,15 @@ func (h *Handler) GetItemById(w http.ResponseWriter, r *http.Request) {
 	item, err := h.itemService.GetById(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, apperror.ErrEmptyString) {
-			response.NotFound(w)
+			response.NotFound(w, r)
 			return
 		}
-		response.InternalError(w, err.Error(), "")
+		response.InternalError(w, r, err.Error(), "")
 		return
 	}
 
-	h.log.Info("GOT ITEM BY ID")
+	h.cache.Items().Add(item.ID, item.ToModel())
+
+	log.Info("GOT ITEM BY ID")
 	response.JSON(w, http.StatusOK, item)
 }
@@ -1,5 +1,7 @@
 package item
 
+import "WBL0/app/internal/model"
+
 type Item struct {
 	ID          int64  `json:"id"`
 	ChrtID      int    `json:"chrt_id"`
@@ -28,3 +30,20 @@ type CreateItemDTO struct {
 	Brand       string `json:"brand"`
 	Status      int    `json:"status"`
 }
+
+func (i *Item) ToModel() *model.Item {
+	return &model.Item{
+		ID:          i.ID,
+		ChrtID:      i.ChrtID,
+		TrackNumber: i.TrackNumber,
+		Price:       i.Price,
+		RID:         i.RID,
+		Name:        i.Name,
+		Sale:        i.Sale,
+		Size:        i.Size,
+		TotalPrice:  i.TotalPrice,
+		NmID:        i.NmID,
+		Brand:       i.Brand,
+		Status:      i.Status,
+	}
+}
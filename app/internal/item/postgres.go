@@ -3,12 +3,12 @@ package item
 import (
 	"WBL0/app/internal/apperror"
 	"WBL0/app/internal/cache"
-	"WBL0/app/internal/model"
 	"WBL0/app/pkg/logger"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"time"
 )
 
@@ -16,27 +16,26 @@ var _ Storage = &ItemStorage{}
 
 type ItemStorage struct {
 	log            logger.Logger
-	conn           *pgx.Conn
+	pool           *pgxpool.Pool
 	requestTimeout time.Duration
-	cache          *cache.Cache
 }
 
-func NewStorage(storage *pgx.Conn, requestTimeout int, cache *cache.Cache) Storage {
+func NewStorage(storage *pgxpool.Pool, requestTimeout int) Storage {
 	return &ItemStorage{
 		log:            logger.GetLogger(),
-		conn:           storage,
+		pool:           storage,
 		requestTimeout: time.Duration(requestTimeout) * time.Second,
-		cache:          cache,
 	}
 }
 
-func (d *ItemStorage) Create(item *Item) (*Item, error) {
-	d.log.Info("POSTGRES: CREATE ITEM")
+func (d *ItemStorage) Create(ctx context.Context, tx pgx.Tx, item *Item) (*Item, error) {
+	log := logger.FromContext(ctx, d.log)
+	log.Info("POSTGRES: CREATE ITEM")
 
-	ctx, cancel := context.WithTimeout(context.Background(), d.requestTimeout)
+	ctx, cancel := context.WithTimeout(ctx, d.requestTimeout)
 	defer cancel()
 
-	row := d.conn.QueryRow(ctx,
+	row := tx.QueryRow(ctx,
 		`INSERT INTO Item (chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status)
 			 VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) 
 			 RETURNING id`,
@@ -46,39 +45,17 @@ func (d *ItemStorage) Create(item *Item) (*Item, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute create item query: %v", err)
 	}
-
-	modelItem := &model.Item{
-		ID:          item.ID,
-		ChrtID:      item.ChrtID,
-		TrackNumber: item.TrackNumber,
-		Price:       item.Price,
-		RID:         item.RID,
-		Name:        item.Name,
-		Sale:        item.Sale,
-		Size:        item.Size,
-		TotalPrice:  item.TotalPrice,
-		NmID:        item.NmID,
-		Brand:       item.Brand,
-		Status:      item.Status,
-	}
-
-	d.cache.Items[item.ID] = modelItem
-
-	fmt.Println("\n\nCache after item creation:")
-	for key, value := range d.cache.Items {
-		fmt.Printf("Key: %d, Value: %+v\n", key, value)
-	}
-
 	return item, nil
 }
 
-func (d *ItemStorage) FindById(id int64) (*Item, error) {
-	d.log.Info("POSTGRES: GET ITEM BY ID")
+func (d *ItemStorage) FindById(ctx context.Context, id int64) (*Item, error) {
+	log := logger.FromContext(ctx, d.log)
+	log.Info("POSTGRES: GET ITEM BY ID")
 
-	ctx, cancel := context.WithTimeout(context.Background(), d.requestTimeout)
+	ctx, cancel := context.WithTimeout(ctx, d.requestTimeout)
 	defer cancel()
 
-	row := d.conn.QueryRow(ctx,
+	row := d.pool.QueryRow(ctx,
 		`SELECT * FROM Item
 			 WHERE id = $1`, id)
 	item := &Item{}
@@ -95,9 +72,9 @@ func (d *ItemStorage) FindById(id int64) (*Item, error) {
 	}
 	return item, nil
 }
-func CacheForItem(dbConn *pgx.Conn, cache *cache.Cache) error {
+func CacheForItem(dbPool *pgxpool.Pool, cache *cache.Cache) error {
 
-	rows, err := dbConn.Query(context.Background(), `SELECT * FROM Item`)
+	rows, err := dbPool.Query(context.Background(), `SELECT * FROM Item`)
 	if err != nil {
 		return err
 	}
@@ -113,22 +90,7 @@ func CacheForItem(dbConn *pgx.Conn, cache *cache.Cache) error {
 			return err
 		}
 
-		modelItem := &model.Item{
-			ID:          item.ID,
-			ChrtID:      item.ChrtID,
-			TrackNumber: item.TrackNumber,
-			Price:       item.Price,
-			RID:         item.RID,
-			Name:        item.Name,
-			Sale:        item.Sale,
-			Size:        item.Size,
-			TotalPrice:  item.TotalPrice,
-			NmID:        item.NmID,
-			Brand:       item.Brand,
-			Status:      item.Status,
-		}
-
-		cache.Items[item.ID] = modelItem
+		cache.Items().Set(item.ID, item.ToModel())
 	}
-	return nil
+	return rows.Err()
 }
@@ -5,10 +5,11 @@ import (
 	"WBL0/app/pkg/logger"
 	"context"
 	"errors"
+	"github.com/jackc/pgx/v4"
 )
 
 type Service interface {
-	Create(ctx context.Context, delivery []*CreateItemDTO) ([]*Item, error)
+	Create(ctx context.Context, tx pgx.Tx, delivery []*CreateItemDTO) ([]*Item, error)
 	GetById(ctx context.Context, id int64) (*Item, error)
 }
 
@@ -23,8 +24,9 @@ func NewService(storage Storage, log logger.Logger) Service {
 		storage: storage,
 	}
 }
-func (s *service) Create(ctx context.Context, items []*CreateItemDTO) ([]*Item, error) {
-	s.log.Info("SERVICE: CREATE ITEM")
+func (s *service) Create(ctx context.Context, tx pgx.Tx, items []*CreateItemDTO) ([]*Item, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: CREATE ITEM")
 
 	createdItems := []*Item{}
 
@@ -42,7 +44,7 @@ func (s *service) Create(ctx context.Context, items []*CreateItemDTO) ([]*Item,
 			Brand:       input.Brand,
 			Status:      input.Status,
 		}
-		item, err := s.storage.Create(&d)
+		item, err := s.storage.Create(ctx, tx, &d)
 		if err != nil {
 			return nil, err
 		}
@@ -52,14 +54,15 @@ func (s *service) Create(ctx context.Context, items []*CreateItemDTO) ([]*Item,
 }
 
 func (s *service) GetById(ctx context.Context, id int64) (*Item, error) {
-	s.log.Info("SERVICE: GET ITEM BY ID")
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: GET ITEM BY ID")
 
-	item, err := s.storage.FindById(id)
+	item, err := s.storage.FindById(ctx, id)
 	if err != nil {
 		if errors.Is(err, apperror.ErrEmptyString) {
 			return nil, err
 		}
-		s.log.Warn("cannot find item by id:", err)
+		log.Warn("cannot find item by id:", err)
 		return nil, err
 	}
 	return item, nil
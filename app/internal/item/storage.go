@@ -1,6 +1,11 @@
 package item
 
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+)
+
 type Storage interface {
-	Create(item *Item) (*Item, error)
-	FindById(id int64) (*Item, error)
+	Create(ctx context.Context, tx pgx.Tx, item *Item) (*Item, error)
+	FindById(ctx context.Context, id int64) (*Item, error)
 }
@@ -0,0 +1,25 @@
+package metrics
+
+import (
+	"WBL0/app/internal/handler"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+const metricsURL = "/metrics"
+
+type Handler struct{}
+
+func NewHandler() handler.Hand {
+	return &Handler{}
+}
+
+func (h *Handler) Permissions() handler.Permissions {
+	return handler.Permissions{
+		handler.Route(http.MethodGet, metricsURL): handler.PermissionPublic,
+	}
+}
+
+func (h *Handler) Register(router handler.Router) {
+	router.HandlerFunc(http.MethodGet, metricsURL, promhttp.Handler().ServeHTTP)
+}
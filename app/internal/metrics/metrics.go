@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"WBL0/app/internal/cache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "wbl0"
+
+var (
+	natsReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "nats_messages_received_total",
+		Help:      "Order messages received from NATS.",
+	})
+	natsSucceeded = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "nats_messages_succeeded_total",
+		Help:      "Order messages ingested successfully.",
+	})
+	natsFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "nats_messages_failed_total",
+		Help:      "Order messages that failed, by the ingestion stage they failed at.",
+	}, []string{"stage"})
+	natsDuplicates = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "nats_duplicates_total",
+		Help:      "Order messages whose order_uid was already stored, by the duplicate policy applied.",
+	}, []string{"policy"})
+
+	ingestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ingest_duration_seconds",
+		Help:      "Time taken to ingest an order message, by result.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"result"})
+
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route pattern and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Cache lookups by entity and result (hit or miss).",
+	}, []string{"entity", "result"})
+)
+
+const (
+	EntityOrder    = "order"
+	EntityDelivery = "delivery"
+	EntityPayment  = "payment"
+	EntityItem     = "item"
+)
+
+func MessageReceived() {
+	natsReceived.Inc()
+}
+
+func MessageSucceeded(seconds float64) {
+	natsSucceeded.Inc()
+	ingestDuration.WithLabelValues("succeeded").Observe(seconds)
+}
+
+func MessageFailed(stage string, seconds float64) {
+	natsFailed.WithLabelValues(stage).Inc()
+	ingestDuration.WithLabelValues("failed").Observe(seconds)
+}
+
+func MessageDuplicated(policy string) {
+	natsDuplicates.WithLabelValues(policy).Inc()
+}
+
+func CacheLookup(entity string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookups.WithLabelValues(entity, result).Inc()
+}
+
+// RegisterCache exposes the entries, approximate size and evictions of the cache per entity, read at scrape time.
+// Size and evictions stay at zero unless the cache is bounded.
+func RegisterCache(c *cache.Cache) {
+	stats := map[string]func() cache.Stats{
+		EntityOrder:    c.Orders().Stats,
+		EntityDelivery: c.Deliveries().Stats,
+		EntityPayment:  c.Payments().Stats,
+		EntityItem:     c.Items().Stats,
+	}
+	for entity, stats := range stats {
+		stats := stats
+		labels := prometheus.Labels{"entity": entity}
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "cache_entries",
+			Help:        "Entries currently held in the cache, by entity.",
+			ConstLabels: labels,
+		}, func() float64 {
+			return float64(stats().Entries)
+		})
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "cache_bytes",
+			Help:        "Approximate size of the cached values, by entity. Only tracked with a max_bytes limit.",
+			ConstLabels: labels,
+		}, func() float64 {
+			return float64(stats().Bytes)
+		})
+		promauto.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "cache_evictions_total",
+			Help:        "Entries dropped from the cache, by entity and reason: capacity (least recently used) or ttl.",
+			ConstLabels: prometheus.Labels{"entity": entity, "reason": "capacity"},
+		}, func() float64 {
+			return float64(stats().Evictions)
+		})
+		promauto.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "cache_evictions_total",
+			Help:        "Entries dropped from the cache, by entity and reason: capacity (least recently used) or ttl.",
+			ConstLabels: prometheus.Labels{"entity": entity, "reason": "ttl"},
+		}, func() float64 {
+			return float64(stats().Expirations)
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"WBL0/app/internal/handler"
+	"WBL0/app/internal/middleware"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type router struct {
+	router handler.Router
+}
+
+// NewRouter records the count and latency of every route registered through it,
+// labelled by the route pattern rather than the request path to keep cardinality bounded.
+func NewRouter(r handler.Router) handler.Router {
+	return &router{router: r}
+}
+
+func (r *router) HandlerFunc(method, path string, next http.HandlerFunc) {
+	r.router.HandlerFunc(method, path, func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rec := middleware.NewRecorder(w)
+		completed := false
+		// Deferred, so that a request whose handler panicked is counted as well
+		defer func() {
+			status := strconv.Itoa(rec.Result(completed))
+			httpRequests.WithLabelValues(method, path, status).Inc()
+			httpDuration.WithLabelValues(method, path, status).Observe(time.Since(start).Seconds())
+		}()
+
+		next(rec, req)
+		completed = true
+	})
+}
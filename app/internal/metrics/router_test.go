@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"WBL0/app/internal/middleware"
+	"WBL0/app/pkg/logger"
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouterCountsPanics(t *testing.T) {
+	router := httprouter.New()
+	router.PanicHandler = middleware.Recover(logger.GetLogger())
+	NewRouter(router).HandlerFunc(http.MethodGet, "/panic/:id", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic/1", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", w.Code)
+	}
+	if n := testutil.ToFloat64(httpRequests.WithLabelValues(http.MethodGet, "/panic/:id", "500")); n != 1 {
+		t.Fatalf("expected the panicked request to be counted as 500, got %v", n)
+	}
+	if n := testutil.CollectAndCount(httpDuration, "wbl0_http_request_duration_seconds"); n == 0 {
+		t.Fatal("expected the panicked request latency to be observed")
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"WBL0/app/internal/handler"
+	"WBL0/app/pkg/logger"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+type accessLog struct {
+	router handler.Router
+	log    logger.Logger
+}
+
+// NewAccessLog writes one line per request to a route registered through it,
+// using the request logger so the line carries the request ID.
+func NewAccessLog(r handler.Router, log logger.Logger) handler.Router {
+	return &accessLog{
+		router: r,
+		log:    log,
+	}
+}
+
+func (a *accessLog) HandlerFunc(method, path string, next http.HandlerFunc) {
+	a.router.HandlerFunc(method, path, func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := NewRecorder(w)
+		completed := false
+		// Deferred, so that a request whose handler panicked is logged as well
+		defer func() {
+			logger.FromContext(r.Context(), a.log).WithFields(logrus.Fields{
+				"method":      method,
+				"route":       path,
+				"status":      rec.Result(completed),
+				"bytes":       rec.Bytes,
+				"duration_ms": float64(time.Since(start).Microseconds()) / 1000,
+			}).Info("ACCESS")
+		}()
+
+		next(rec, r)
+		completed = true
+	})
+}
+
+// Recorder keeps the status code and body size written to a response.
+type Recorder struct {
+	http.ResponseWriter
+	Status  int
+	Bytes   int
+	written bool
+}
+
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (r *Recorder) WriteHeader(status int) {
+	r.Status = status
+	r.written = true
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.Bytes += n
+	r.written = true
+	return n, err
+}
+
+// Result returns the status to report for the response. A handler that did not complete,
+// because it panicked, before writing anything is answered with 500 by Recover.
+func (r *Recorder) Result(completed bool) int {
+	if !completed && !r.written {
+		return http.StatusInternalServerError
+	}
+	return r.Status
+}
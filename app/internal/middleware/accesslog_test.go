@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"WBL0/app/pkg/config"
+	"WBL0/app/pkg/logger"
+	"bytes"
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func accessLines(t *testing.T, out *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var lines []map[string]interface{}
+	for _, raw := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var line map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &line); err != nil {
+			t.Fatalf("expected a JSON line, got %q: %v", raw, err)
+		}
+		if line["msg"] == "ACCESS" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestAccessLogRecordsPanics(t *testing.T) {
+	var cfg config.Config
+	cfg.Log.Level = "info"
+	cfg.Log.Format = logger.FormatJSON
+	var out bytes.Buffer
+	if err := logger.InitWithWriter(cfg, &out); err != nil {
+		t.Fatal(err)
+	}
+	log := logger.GetLogger()
+
+	router := httprouter.New()
+	router.PanicHandler = Recover(log)
+	accessLog := NewAccessLog(router, log)
+	accessLog.HandlerFunc(http.MethodGet, "/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	accessLog.HandlerFunc(http.MethodGet, "/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	accessLog.HandlerFunc(http.MethodGet, "/panic-after-header", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("boom")
+	})
+
+	cases := map[string]float64{
+		"/ok":                 http.StatusOK,
+		"/panic":              http.StatusInternalServerError,
+		"/panic-after-header": http.StatusAccepted,
+	}
+	for path, want := range cases {
+		out.Reset()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		lines := accessLines(t, &out)
+		if len(lines) != 1 {
+			t.Fatalf("%s: expected one access line, got %d: %s", path, len(lines), out.String())
+		}
+		if lines[0]["status"] != want || lines[0]["route"] != path {
+			t.Errorf("%s: unexpected access line: %v", path, lines[0])
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"WBL0/app/internal/response"
+	"WBL0/app/pkg/logger"
+	"net/http"
+	"runtime/debug"
+)
+
+// Recover is used as httprouter's PanicHandler: it logs the panic with its stack
+// through the request logger and answers with a 500 AppError instead of dropping the connection.
+func Recover(log logger.Logger) func(http.ResponseWriter, *http.Request, interface{}) {
+	return func(w http.ResponseWriter, r *http.Request, rec interface{}) {
+		logger.FromContext(r.Context(), log).Errorf("panic in %s %s: %v\n%s", r.Method, r.URL.Path, rec, debug.Stack())
+		response.InternalError(w, r, "internal server error", "")
+	}
+}
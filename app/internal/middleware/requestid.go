@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"WBL0/app/pkg/logger"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+const (
+	HeaderRequestID  = "X-Request-ID"
+	maxRequestIDSize = 128
+)
+
+// RequestID takes the caller's X-Request-ID, or makes one up, echoes it in the
+// response and stores a logger carrying it in the request context, see logger.FromContext.
+func RequestID(next http.Handler, log logger.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(HeaderRequestID)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(HeaderRequestID, id)
+
+		requestLog := log.GetLoggerWithField("request_id", id)
+		next.ServeHTTP(w, r.WithContext(logger.ContextWithLogger(r.Context(), *requestLog)))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDSize {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
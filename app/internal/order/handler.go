@@ -4,62 +4,105 @@ import (
 	"WBL0/app/internal/apperror"
 	"WBL0/app/internal/cache"
 	"WBL0/app/internal/handler"
+	"WBL0/app/internal/metrics"
+	"WBL0/app/internal/model"
 	"WBL0/app/internal/response"
 	"WBL0/app/pkg/config"
 	"WBL0/app/pkg/logger"
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/nats-io/nats.go"
-
-	"github.com/julienschmidt/httprouter"
 	"net/http"
+	"time"
 )
 
 const (
 	orderURL     = "/order"
 	orderByIdURL = "/order/:id"
+	ordersURL    = "/orders"
+
+	ordersByTrackURL  = "/orders/by-track/:track"
+	customerOrdersURL = "/customers/:id/orders"
 )
 
 type Handler struct {
 	cfg          config.Config
 	log          logger.Logger
-	natsConn     nats.Conn
+	natsConn     *nats.Conn
+	js           nats.JetStreamContext
 	orderService Service
 	cache        *cache.Cache
 }
 
-func NewHandler(cfg config.Config, log logger.Logger, natsConn nats.Conn, orderService Service, cache *cache.Cache) handler.Hand {
+// NewHandler publishes orders through js when it is not nil, and through core NATS otherwise.
+func NewHandler(cfg config.Config, log logger.Logger, natsConn *nats.Conn, js nats.JetStreamContext, orderService Service, cache *cache.Cache) handler.Hand {
 	return &Handler{
 		cfg:          cfg,
 		log:          log,
 		natsConn:     natsConn,
+		js:           js,
 		orderService: orderService,
 		cache:        cache,
 	}
 }
 
-func (h *Handler) Register(router *httprouter.Router) {
+func (h *Handler) Permissions() handler.Permissions {
+	return handler.Permissions{
+		handler.Route(http.MethodPost, orderURL):    handler.PermissionPublish,
+		handler.Route(http.MethodGet, orderByIdURL): handler.PermissionRead,
+		handler.Route(http.MethodGet, ordersURL):    handler.PermissionRead,
+
+		handler.Route(http.MethodGet, ordersByTrackURL):  handler.PermissionRead,
+		handler.Route(http.MethodGet, customerOrdersURL): handler.PermissionRead,
+	}
+}
+
+func (h *Handler) Register(router handler.Router) {
 	router.HandlerFunc(http.MethodPost, orderURL, h.CreateOrder)
 	router.HandlerFunc(http.MethodGet, orderByIdURL, h.GetOrderById)
+	router.HandlerFunc(http.MethodGet, ordersURL, h.GetOrders)
+	router.HandlerFunc(http.MethodGet, ordersByTrackURL, h.GetOrdersByTrack)
+	router.HandlerFunc(http.MethodGet, customerOrdersURL, h.GetCustomerOrders)
 }
 
 func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
-	h.log.Info("HANDLER: CREATE ORDER")
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("HANDLER: CREATE ORDER")
 
 	var input Order
 
 	if err := response.ReadJSON(w, r, &input); err != nil {
-		h.log.Info("Invalid data type")
-		response.BadRequest(w, err.Error(), apperror.ErrInvalidRequestBody.Error())
+		log.Info("Invalid data type")
+		response.BadRequest(w, r, err.Error(), apperror.ErrInvalidRequestBody.Error())
+		return
+	}
+
+	if err := input.Validate(); err != nil {
+		var validationErr *apperror.ValidationError
+		if errors.As(err, &validationErr) {
+			log.Info("Invalid order")
+			response.ValidationError(w, r, validationErr)
+			return
+		}
+		response.BadRequest(w, r, err.Error(), "")
 		return
 	}
 
 	orderJSON, err := json.Marshal(input)
 	subject := h.cfg.NATS.SUB
 
-	err = h.natsConn.Publish(subject, orderJSON)
+	if h.js != nil {
+		// Publish waits for the stream's ack, so a success means the order is stored
+		ctx, cancel := context.WithTimeout(r.Context(), time.Duration(h.cfg.NATS.RequestTimeout)*time.Second)
+		_, err = h.js.Publish(subject, orderJSON, nats.Context(ctx))
+		cancel()
+	} else {
+		err = h.natsConn.Publish(subject, orderJSON)
+	}
 	if err != nil {
-		response.BadRequest(w, err.Error(), "")
+		log.Warn("cannot publish order: ", err)
+		response.Error(w, r, http.StatusServiceUnavailable, err.Error(), "order has not been accepted, try again later")
 		return
 	}
 
@@ -67,32 +110,149 @@ func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) GetOrderById(w http.ResponseWriter, r *http.Request) {
-	h.log.Infof("HANDLER: GET ORDER BY ID")
+	log := logger.FromContext(r.Context(), h.log)
+	log.Infof("HANDLER: GET ORDER BY ID")
 
-	uid := r.URL.Query().Get("uid")
-	h.log.Info("Input: ", uid)
-	if uid == "" {
-		response.BadRequest(w, "empty uid", "")
+	uid, err := handler.ReadUidParam(r)
+	log.Info("Input: ", uid)
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), "")
 		return
 	}
 
-	cacheOrder, ok := h.cache.Orders[uid]
+	cacheOrder, ok := h.fromCache(uid)
+	metrics.CacheLookup(metrics.EntityOrder, ok)
 	if ok {
-		h.log.Info("GOT ORDER FROM CACHE BY ID")
+		log.Info("GOT ORDER FROM CACHE BY ID")
 		response.JSON(w, http.StatusOK, cacheOrder)
 		return
 	}
 
-	order, err := h.orderService.GetById(r.Context(), uid)
+	parts, err := h.orderService.GetPartsById(r.Context(), uid)
 	if err != nil {
 		if errors.Is(err, apperror.ErrEmptyString) {
-			response.NotFound(w)
+			response.NotFound(w, r)
 			return
 		}
-		response.InternalError(w, err.Error(), "")
+		response.InternalError(w, r, err.Error(), "")
+		return
+	}
+	parts.AddToCache(h.cache)
+
+	log.Info("GOT ORDER BY ID")
+	response.JSON(w, http.StatusOK, parts.Full())
+}
+
+// fromCache assembles the order only when every part of it is cached.
+func (h *Handler) fromCache(uid string) (*Order, bool) {
+	o, ok := h.cache.Orders().Get(uid)
+	if !ok {
+		return nil, false
+	}
+	d, ok := h.cache.Deliveries().Get(o.Delivery)
+	if !ok {
+		return nil, false
+	}
+	p, ok := h.cache.Payments().Get(o.Payment)
+	if !ok {
+		return nil, false
+	}
+	items := make([]*model.Item, 0, len(o.Items))
+	for _, id := range o.Items {
+		i, ok := h.cache.Items().Get(id)
+		if !ok {
+			return nil, false
+		}
+		items = append(items, i)
+	}
+	return NewOrderFromModel(o, d, p, items), true
+}
+
+// GetOrders lists orders from the cache, or from Postgres with ?source=db.
+func (h *Handler) GetOrders(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("HANDLER: GET ORDERS")
+
+	q, err := ParseListQuery(r.URL.Query())
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), "")
+		return
+	}
+
+	h.listOrders(w, r, q)
+}
+
+// GetOrdersByTrack lists the orders that carry the track number themselves or on one of their items.
+func (h *Handler) GetOrdersByTrack(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("HANDLER: GET ORDERS BY TRACK")
+
+	track, err := handler.ReadStringParam(r, "track")
+	log.Info("Input: ", track)
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), "")
 		return
 	}
 
-	h.log.Info("GOT ORDER BY ID")
-	response.JSON(w, http.StatusOK, order)
+	q, err := ParseListQuery(r.URL.Query())
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), "")
+		return
+	}
+	q.Filter.Track = track
+
+	h.listOrders(w, r, q)
+}
+
+func (h *Handler) GetCustomerOrders(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("HANDLER: GET CUSTOMER ORDERS")
+
+	customerID, err := handler.ReadUidParam(r)
+	log.Info("Input: ", customerID)
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), "")
+		return
+	}
+
+	q, err := ParseListQuery(r.URL.Query())
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), "")
+		return
+	}
+	q.Filter.CustomerID = customerID
+
+	h.listOrders(w, r, q)
+}
+
+// listOrders serves q from the cache, or from Postgres with ?source=db, when the cache is bounded
+// or while it is not fully loaded.
+func (h *Handler) listOrders(w http.ResponseWriter, r *http.Request, q *ListQuery) {
+	log := logger.FromContext(r.Context(), h.log)
+
+	source := r.URL.Query().Get("source")
+	if source == "" && h.cache.Bounded() {
+		// A bounded cache holds only some orders, so listings default to the DB
+		source = "db"
+	}
+	if done, err := h.cache.LoadState(); source == "" && (!done || err != nil) {
+		// Until the warm-up has finished the cache would return incomplete pages
+		source = "db"
+	}
+
+	switch source {
+	case "", "cache":
+		log.Info("GOT ORDERS FROM CACHE")
+		response.JSON(w, http.StatusOK, q.Page(ListFromCache(h.cache, q)))
+	case "db":
+		result, err := h.orderService.GetAll(r.Context(), q)
+		if err != nil {
+			response.InternalError(w, r, err.Error(), "")
+			return
+		}
+		log.Info("GOT ORDERS")
+		response.JSON(w, http.StatusOK, result)
+	default:
+		response.BadRequest(w, r, "source must be cache or db", "")
+	}
 }
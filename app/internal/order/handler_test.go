@@ -0,0 +1,73 @@
+package order
+
+import (
+	"WBL0/app/internal/cache"
+	"WBL0/app/internal/model"
+	"WBL0/app/pkg/config"
+	"WBL0/app/pkg/logger"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// dbService stands in for Postgres in listings, returning a single order.
+type dbService struct {
+	Service
+	calls int
+}
+
+func (s *dbService) GetAll(ctx context.Context, q *ListQuery) (*ListResult, error) {
+	s.calls++
+	return &ListResult{Orders: []*model.Order{{OrderUID: "from-db"}}}, nil
+}
+
+func TestListingsDefaultToDBUntilCacheLoaded(t *testing.T) {
+	paths := []string{"/orders", "/orders/by-track/TRACK1", "/customers/c1/orders"}
+
+	cases := []struct {
+		name  string
+		load  func(c *cache.Cache)
+		query string
+		db    bool
+	}{
+		{"loading", func(*cache.Cache) {}, "", true},
+		{"loading, cache requested", func(*cache.Cache) {}, "?source=cache", false},
+		{"load failed", func(c *cache.Cache) { c.FinishLoad(errors.New("db is down")) }, "", true},
+		{"loaded", func(c *cache.Cache) { c.FinishLoad(nil) }, "", false},
+		{"loaded, db requested", func(c *cache.Cache) { c.FinishLoad(nil) }, "?source=db", true},
+	}
+	for _, c := range cases {
+		orderCache := testCache(testOrders())
+		c.load(orderCache)
+		orderService := &dbService{}
+		router := httprouter.New()
+		NewHandler(config.Config{}, logger.GetLogger(), nil, nil, orderService, orderCache).Register(router)
+
+		for _, path := range paths {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path+c.query, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s %s: got status %d", c.name, path, w.Code)
+			}
+			result := &ListResult{}
+			if err := json.Unmarshal(w.Body.Bytes(), result); err != nil {
+				t.Fatal(err)
+			}
+			fromDB := len(result.Orders) == 1 && result.Orders[0].OrderUID == "from-db"
+			if fromDB != c.db {
+				t.Errorf("%s %s: read from the DB %v, want %v", c.name, path, fromDB, c.db)
+			}
+		}
+		want := 0
+		if c.db {
+			want = len(paths)
+		}
+		if orderService.calls != want {
+			t.Errorf("%s: got %d DB listings, want %d", c.name, orderService.calls, want)
+		}
+	}
+}
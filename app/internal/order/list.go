@@ -0,0 +1,226 @@
+package order
+
+import (
+	"WBL0/app/internal/cache"
+	"WBL0/app/internal/model"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+const (
+	SortDateCreated = "date_created"
+	SortOrderUID    = "order_uid"
+)
+
+type ListFilter struct {
+	CustomerID      string
+	TrackNumber     string
+	DeliveryService string
+	Entry           string
+	Locale          string
+	DateFrom        *time.Time
+	DateTo          *time.Time
+
+	// Track matches the track number of the order or of any of its items. It is set by
+	// GET /orders/by-track/:track: the cache serves it from its track index, Postgres, with
+	// ?source=db or for a bounded cache, through the track_number indexes of "order" and Item.
+	Track string
+}
+
+// Cursor points at the last order of a page: its sort value and order_uid as a tie-breaker.
+type Cursor struct {
+	Value string `json:"v"`
+	UID   string `json:"u"`
+}
+
+type ListQuery struct {
+	Filter ListFilter
+	Sort   string
+	Desc   bool
+	Limit  int
+	After  *Cursor
+}
+
+type ListResult struct {
+	Orders     []*model.Order `json:"orders"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// ParseListQuery reads filters, sorting and pagination from the GET /orders query string.
+// Sort is a field name, prefixed with "-" for descending order.
+func ParseListQuery(values url.Values) (*ListQuery, error) {
+	q := &ListQuery{
+		Filter: ListFilter{
+			CustomerID:      values.Get("customer_id"),
+			TrackNumber:     values.Get("track_number"),
+			DeliveryService: values.Get("delivery_service"),
+			Entry:           values.Get("entry"),
+			Locale:          values.Get("locale"),
+		},
+		Sort:  SortDateCreated,
+		Desc:  true,
+		Limit: defaultListLimit,
+	}
+
+	for name, dest := range map[string]**time.Time{"date_from": &q.Filter.DateFrom, "date_to": &q.Filter.DateTo} {
+		if v := values.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+			}
+			*dest = &t
+		}
+	}
+
+	if v := values.Get("sort"); v != "" {
+		q.Desc = strings.HasPrefix(v, "-")
+		q.Sort = strings.TrimPrefix(v, "-")
+		if q.Sort != SortDateCreated && q.Sort != SortOrderUID {
+			return nil, fmt.Errorf("sort must be one of %s, %s", SortDateCreated, SortOrderUID)
+		}
+	}
+
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+		}
+		q.Limit = limit
+	}
+
+	if v := values.Get("cursor"); v != "" {
+		cursor, err := DecodeCursor(v)
+		if err != nil {
+			return nil, err
+		}
+		q.After = cursor
+	}
+	return q, nil
+}
+
+func EncodeCursor(c *Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	c := &Cursor{}
+	if err = json.Unmarshal(data, c); err != nil || c.UID == "" {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return c, nil
+}
+
+func (q *ListQuery) sortValue(o *model.Order) string {
+	if q.Sort == SortOrderUID {
+		return o.OrderUID
+	}
+	return o.DateCreated
+}
+
+// less orders by the sort value with order_uid as a tie-breaker, comparing bytes like COLLATE "C".
+func (q *ListQuery) less(a, b *Cursor) bool {
+	x, y := a, b
+	if q.Desc {
+		x, y = b, a
+	}
+	if x.Value != y.Value {
+		return x.Value < y.Value
+	}
+	return x.UID < y.UID
+}
+
+func (q *ListQuery) match(o *model.Order) bool {
+	f := q.Filter
+	switch {
+	case f.CustomerID != "" && o.CustomerID != f.CustomerID,
+		f.TrackNumber != "" && o.TrackNumber != f.TrackNumber,
+		f.DeliveryService != "" && o.DeliveryService != f.DeliveryService,
+		f.Entry != "" && o.Entry != f.Entry,
+		f.Locale != "" && o.Locale != f.Locale:
+		return false
+	}
+	if f.DateFrom != nil || f.DateTo != nil {
+		created, err := time.Parse(time.RFC3339, o.DateCreated)
+		if err != nil {
+			return false
+		}
+		if f.DateFrom != nil && created.Before(*f.DateFrom) {
+			return false
+		}
+		if f.DateTo != nil && created.After(*f.DateTo) {
+			return false
+		}
+	}
+	if q.After != nil {
+		return q.less(q.After, &Cursor{Value: q.sortValue(o), UID: o.OrderUID})
+	}
+	return true
+}
+
+// Page cuts orders, already sorted and fetched with one extra row, to the limit
+// and sets the cursor of the next page when there is one.
+func (q *ListQuery) Page(orders []*model.Order) *ListResult {
+	result := &ListResult{Orders: orders}
+	if len(orders) > q.Limit {
+		result.Orders = orders[:q.Limit]
+		last := result.Orders[q.Limit-1]
+		result.NextCursor = EncodeCursor(&Cursor{Value: q.sortValue(last), UID: last.OrderUID})
+	}
+	return result
+}
+
+// ListFromCache returns up to Limit+1 matching orders from the cache. Track and customer
+// filters pick the candidates from the cache's secondary indexes instead of scanning every order.
+func ListFromCache(c *cache.Cache, q *ListQuery) []*model.Order {
+	orders := []*model.Order{}
+	switch {
+	case q.Filter.Track != "":
+		orders = filter(c.OrdersByTrack(q.Filter.Track), q)
+	case q.Filter.CustomerID != "":
+		orders = filter(c.OrdersByCustomer(q.Filter.CustomerID), q)
+	default:
+		c.Orders().Range(func(uid string, o *model.Order) bool {
+			if q.match(o) {
+				orders = append(orders, o)
+			}
+			return true
+		})
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		return q.less(
+			&Cursor{Value: q.sortValue(orders[i]), UID: orders[i].OrderUID},
+			&Cursor{Value: q.sortValue(orders[j]), UID: orders[j].OrderUID},
+		)
+	})
+	if len(orders) > q.Limit+1 {
+		orders = orders[:q.Limit+1]
+	}
+	return orders
+}
+
+func filter(candidates []*model.Order, q *ListQuery) []*model.Order {
+	orders := candidates[:0]
+	for _, o := range candidates {
+		if q.match(o) {
+			orders = append(orders, o)
+		}
+	}
+	return orders
+}
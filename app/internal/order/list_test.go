@@ -0,0 +1,352 @@
+package order
+
+import (
+	"WBL0/app/internal/cache"
+	"WBL0/app/internal/model"
+	postgres "WBL0/app/pkg/storage"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursors := []*Cursor{
+		{Value: "2021-11-26T06:22:19Z", UID: "b563feb7b2b84b6test"},
+		{Value: "", UID: "a"},
+		{Value: "Zürich \"quoted\" / +=&?", UID: "é_1"},
+	}
+	for _, c := range cursors {
+		encoded := EncodeCursor(c)
+		if url.QueryEscape(encoded) != encoded {
+			t.Errorf("cursor %q is not safe in a query string", encoded)
+		}
+		decoded, err := DecodeCursor(encoded)
+		if err != nil {
+			t.Fatalf("cannot decode cursor of %+v: %v", c, err)
+		}
+		if *decoded != *c {
+			t.Errorf("cursor round trip: got %+v, want %+v", decoded, c)
+		}
+
+		q, err := ParseListQuery(url.Values{"cursor": {encoded}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q.After == nil || *q.After != *c {
+			t.Errorf("ParseListQuery cursor: got %+v, want %+v", q.After, c)
+		}
+	}
+}
+
+func TestDecodeCursorRejectsInvalid(t *testing.T) {
+	for _, s := range []string{
+		"not base64!",
+		EncodeCursor(&Cursor{Value: "2021-11-26T06:22:19Z"}),
+		"bm90IGpzb24",
+	} {
+		if _, err := DecodeCursor(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestParseListQuery(t *testing.T) {
+	q, err := ParseListQuery(url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Sort != SortDateCreated || !q.Desc || q.Limit != defaultListLimit {
+		t.Fatalf("unexpected defaults: %+v", q)
+	}
+
+	q, err = ParseListQuery(url.Values{"sort": {SortOrderUID}, "limit": {"5"}, "date_from": {"2021-11-26T00:00:00Z"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Sort != SortOrderUID || q.Desc || q.Limit != 5 || q.Filter.DateFrom == nil {
+		t.Fatalf("unexpected query: %+v", q)
+	}
+
+	for _, values := range []url.Values{
+		{"sort": {"-customer_id"}},
+		{"limit": {"0"}},
+		{"limit": {"101"}},
+		{"date_to": {"yesterday"}},
+	} {
+		if _, err = ParseListQuery(values); err == nil {
+			t.Errorf("expected %v to be rejected", values)
+		}
+	}
+}
+
+// testOrders mix cases, digits, punctuation and non-ASCII letters, whose order under
+// COLLATE "C" differs from a linguistic one, and share dates to exercise the tie-breaker.
+func testOrders() []*model.Order {
+	orders := []*model.Order{
+		{OrderUID: "a1", TrackNumber: "TRACK1", CustomerID: "c1", Locale: "en", DateCreated: "2021-11-26T06:22:19Z"},
+		{OrderUID: "B2", TrackNumber: "TRACK1", CustomerID: "c1", Locale: "ru", DateCreated: "2021-11-26T06:22:19Z"},
+		{OrderUID: "Z3", TrackNumber: "TRACK2", CustomerID: "c2", Locale: "en", DateCreated: "2021-11-25T23:59:59Z"},
+		{OrderUID: "é4", TrackNumber: "TRACK2", CustomerID: "c1", Locale: "en", DateCreated: "2021-11-27T00:00:00Z"},
+		{OrderUID: "_5", TrackNumber: "TRACK3", CustomerID: "c2", Locale: "ru", DateCreated: "2021-11-26T06:22:19Z"},
+		{OrderUID: "a10", TrackNumber: "TRACK3", CustomerID: "c1", Locale: "en", DateCreated: "2021-11-24T10:00:00Z"},
+		{OrderUID: "a2", TrackNumber: "TRACK1", CustomerID: "c2", Locale: "en", DateCreated: "2021-11-27T00:00:00Z"},
+		{OrderUID: "Éa", TrackNumber: "TRACK3", CustomerID: "c1", Locale: "ru", DateCreated: "2021-11-25T23:59:59Z"},
+	}
+	for i, o := range orders {
+		o.Delivery = int64(i + 1)
+		o.Payment = int64(i + 1)
+		o.Items = []int64{int64(i + 1)}
+	}
+	return orders
+}
+
+// testItem gives a1 and _5 an item on TRACK2, so that by-track lookups also go through items.
+func testItem(o *model.Order) *model.Item {
+	track := o.TrackNumber
+	if o.OrderUID == "a1" || o.OrderUID == "_5" {
+		track = "TRACK2"
+	}
+	return &model.Item{ID: o.Items[0], TrackNumber: track}
+}
+
+func testQueries() map[string]url.Values {
+	return map[string]url.Values{
+		"date desc":           {},
+		"date asc":            {"sort": {SortDateCreated}},
+		"uid asc":             {"sort": {SortOrderUID}},
+		"uid desc":            {"sort": {"-" + SortOrderUID}},
+		"customer":            {"customer_id": {"c1"}},
+		"customer uid asc":    {"customer_id": {"c1"}, "sort": {SortOrderUID}},
+		"locale and track":    {"locale": {"en"}, "track_number": {"TRACK1"}, "sort": {SortOrderUID}},
+		"date range":          {"date_from": {"2021-11-25T23:59:59Z"}, "date_to": {"2021-11-26T06:22:19Z"}},
+		"no match":            {"customer_id": {"nobody"}},
+		"date range uid desc": {"date_from": {"2021-11-26T00:00:00Z"}, "sort": {"-" + SortOrderUID}},
+	}
+}
+
+// expectedUIDs sorts the matching orders by comparing bytes, which is what COLLATE "C" does for UTF-8.
+func expectedUIDs(orders []*model.Order, q *ListQuery) []string {
+	var matched []*model.Order
+	for _, o := range orders {
+		if q.match(o) {
+			matched = append(matched, o)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if q.Desc {
+			a, b = b, a
+		}
+		if c := bytes.Compare([]byte(q.sortValue(a)), []byte(q.sortValue(b))); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare([]byte(a.OrderUID), []byte(b.OrderUID)) < 0
+	})
+	uids := make([]string, 0, len(matched))
+	for _, o := range matched {
+		uids = append(uids, o.OrderUID)
+	}
+	return uids
+}
+
+// walkPages follows next cursors from the first page to the last and returns every order_uid listed.
+func walkPages(t *testing.T, values url.Values, limit int, list func(q *ListQuery) []*model.Order) []string {
+	t.Helper()
+
+	var uids []string
+	cursor := ""
+	for page := 0; ; page++ {
+		if page > 20 {
+			t.Fatal("pagination does not end")
+		}
+		v := url.Values{}
+		for name, value := range values {
+			v[name] = value
+		}
+		v.Set("limit", fmt.Sprint(limit))
+		if cursor != "" {
+			v.Set("cursor", cursor)
+		}
+		q, err := ParseListQuery(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		orders := list(q)
+		if len(orders) > limit+1 {
+			t.Fatalf("fetched %d orders for a limit of %d", len(orders), limit)
+		}
+		result := q.Page(orders)
+		for _, o := range result.Orders {
+			uids = append(uids, o.OrderUID)
+		}
+		if result.NextCursor == "" {
+			return uids
+		}
+		cursor = result.NextCursor
+	}
+}
+
+func testCache(orders []*model.Order) *cache.Cache {
+	c := cache.NewCache()
+	for _, o := range orders {
+		c.Items().Set(o.Items[0], testItem(o))
+		c.Orders().Set(o.OrderUID, o)
+	}
+	return c
+}
+
+func listCache(c *cache.Cache) func(q *ListQuery) []*model.Order {
+	return func(q *ListQuery) []*model.Order {
+		return ListFromCache(c, q)
+	}
+}
+
+func TestListFromCachePagesInByteOrder(t *testing.T) {
+	orders := testOrders()
+	list := listCache(testCache(orders))
+
+	for name, values := range testQueries() {
+		q, err := ParseListQuery(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := expectedUIDs(orders, q)
+		for _, limit := range []int{1, 2, 3, 100} {
+			got := walkPages(t, values, limit, list)
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("%s, limit %d: got %v, want %v", name, limit, got, want)
+			}
+		}
+	}
+}
+
+func TestListFromCacheByTrack(t *testing.T) {
+	c := testCache(testOrders())
+
+	q, err := ParseListQuery(url.Values{"sort": {SortOrderUID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Filter.Track = "TRACK2"
+
+	var got []string
+	for _, o := range ListFromCache(c, q) {
+		got = append(got, o.OrderUID)
+	}
+	// Z3 and é4 carry the track themselves, a1 and _5 through their items
+	if want := "Z3,_5,a1,é4"; strings.Join(got, ",") != want {
+		t.Fatalf("got %v, want %s", got, want)
+	}
+}
+
+// testPool connects to the database in TEST_DATABASE_DSN and migrates a scratch schema, which is
+// dropped once the test is done. Tests that need Postgres are skipped without the variable.
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	ctx := context.Background()
+
+	admin, err := pgxpool.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(admin.Close)
+	schema := fmt.Sprintf("order_test_%d", time.Now().UnixNano())
+	if _, err = admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+	})
+
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.ConnectConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	if _, err = postgres.MigrateUp(ctx, pool); err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+func TestFindAllMatchesCacheOrder(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	orders := testOrders()
+
+	for _, o := range orders {
+		item := testItem(o)
+		_, err := pool.Exec(ctx, `INSERT INTO Delivery (id) VALUES ($1)`, o.Delivery)
+		if err == nil {
+			_, err = pool.Exec(ctx, `INSERT INTO Payment (id) VALUES ($1)`, o.Payment)
+		}
+		if err == nil {
+			_, err = pool.Exec(ctx, `INSERT INTO Item (id, track_number) VALUES ($1, $2)`, item.ID, item.TrackNumber)
+		}
+		if err == nil {
+			_, err = pool.Exec(ctx, `INSERT INTO "order" (order_uid, track_number, delivery, payment, locale, customer_id, date_created)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+				o.OrderUID, o.TrackNumber, o.Delivery, o.Payment, o.Locale, o.CustomerID, o.DateCreated)
+		}
+		if err == nil {
+			_, err = pool.Exec(ctx, `INSERT INTO order_items (order_uid, item_id, position) VALUES ($1, $2, 1)`, o.OrderUID, item.ID)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	storage := NewStorage(pool, 5)
+	listDB := func(q *ListQuery) []*model.Order {
+		found, err := storage.FindAll(ctx, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		orders := make([]*model.Order, 0, len(found))
+		for _, o := range found {
+			orders = append(orders, &model.Order{OrderUID: o.OrderUID, DateCreated: o.DateCreated})
+		}
+		return orders
+	}
+	listCache := listCache(testCache(orders))
+
+	queries := testQueries()
+	queries["track"] = url.Values{"sort": {SortOrderUID}}
+	for name, values := range queries {
+		for _, limit := range []int{1, 3, 100} {
+			withTrack := func(list func(q *ListQuery) []*model.Order) func(q *ListQuery) []*model.Order {
+				if name != "track" {
+					return list
+				}
+				return func(q *ListQuery) []*model.Order {
+					q.Filter.Track = "TRACK2"
+					return list(q)
+				}
+			}
+			fromDB := walkPages(t, values, limit, withTrack(listDB))
+			fromCache := walkPages(t, values, limit, withTrack(listCache))
+			if strings.Join(fromDB, ",") != strings.Join(fromCache, ",") {
+				t.Errorf("%s, limit %d: Postgres listed %v, the cache %v", name, limit, fromDB, fromCache)
+			}
+		}
+	}
+}
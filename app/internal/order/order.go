@@ -1,5 +1,10 @@
 package order
 
+import (
+	"WBL0/app/internal/cache"
+	"WBL0/app/internal/model"
+)
+
 type Delivery struct {
 	Name    string `json:"name"`
 	Phone   string `json:"phone"`
@@ -70,3 +75,118 @@ type CreateOrderDTO struct {
 	DateCreated       string `json:"date_created"`
 	OofShard          string `json:"oof_shard"`
 }
+
+func (o *CreateOrderDTO) ToModel() *model.Order {
+	return &model.Order{
+		OrderUID:          o.OrderUID,
+		TrackNumber:       o.TrackNumber,
+		Entry:             o.Entry,
+		Delivery:          o.Delivery,
+		Payment:           o.Payment,
+		Items:             o.Items,
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerID:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		ShardKey:          o.ShardKey,
+		SMID:              o.SMID,
+		DateCreated:       o.DateCreated,
+		OofShard:          o.OofShard,
+	}
+}
+
+// NewOrderFromModel assembles the nested order document from cached parts.
+func NewOrderFromModel(o *model.Order, d *model.Delivery, p *model.Payment, items []*model.Item) *Order {
+	order := &Order{
+		OrderUID:    o.OrderUID,
+		TrackNumber: o.TrackNumber,
+		Entry:       o.Entry,
+		Delivery: Delivery{
+			Name:    d.Name,
+			Phone:   d.Phone,
+			Zip:     d.Zip,
+			City:    d.City,
+			Address: d.Address,
+			Region:  d.Region,
+			Email:   d.Email,
+		},
+		Payment: Payment{
+			Transaction:  p.Transaction,
+			RequestID:    p.RequestID,
+			Currency:     p.Currency,
+			Provider:     p.Provider,
+			Amount:       p.Amount,
+			PaymentDt:    p.PaymentDt,
+			Bank:         p.Bank,
+			DeliveryCost: p.DeliveryCost,
+			GoodsTotal:   p.GoodsTotal,
+			CustomFee:    p.CustomFee,
+		},
+		Items:             make([]Item, 0, len(items)),
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerID:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		ShardKey:          o.ShardKey,
+		SMID:              o.SMID,
+		DateCreated:       o.DateCreated,
+		OofShard:          o.OofShard,
+	}
+	for _, i := range items {
+		order.Items = append(order.Items, Item{
+			ChrtID:      i.ChrtID,
+			TrackNumber: i.TrackNumber,
+			Price:       i.Price,
+			RID:         i.RID,
+			Name:        i.Name,
+			Sale:        i.Sale,
+			Size:        i.Size,
+			TotalPrice:  i.TotalPrice,
+			NmID:        i.NmID,
+			Brand:       i.Brand,
+			Status:      i.Status,
+		})
+	}
+	return order
+}
+
+// Parts is an order split the way it is cached: the order referring to its parts by ID, and the parts.
+type Parts struct {
+	Order    *model.Order
+	Delivery *model.Delivery
+	Payment  *model.Payment
+	Items    []*model.Item
+}
+
+func (p *Parts) Full() *Order {
+	return NewOrderFromModel(p.Order, p.Delivery, p.Payment, p.Items)
+}
+
+// AddToCache adds the parts that are not cached yet. The order goes last,
+// so it is never cached before the parts it refers to.
+func (p *Parts) AddToCache(c *cache.Cache) {
+	c.Deliveries().Add(p.Delivery.ID, p.Delivery)
+	c.Payments().Add(p.Payment.ID, p.Payment)
+	for _, i := range p.Items {
+		c.Items().Add(i.ID, i)
+	}
+	c.Orders().Add(p.Order.OrderUID, p.Order)
+}
+
+// SetInCache caches the parts over what is cached, and drops the parts of the cached order they replace.
+func (p *Parts) SetInCache(c *cache.Cache) {
+	if old, ok := c.Orders().Get(p.Order.OrderUID); ok {
+		c.Deliveries().Delete(old.Delivery)
+		c.Payments().Delete(old.Payment)
+		for _, id := range old.Items {
+			c.Items().Delete(id)
+		}
+	}
+
+	c.Deliveries().Set(p.Delivery.ID, p.Delivery)
+	c.Payments().Set(p.Payment.ID, p.Payment)
+	for _, i := range p.Items {
+		c.Items().Set(i.ID, i)
+	}
+	c.Orders().Set(p.Order.OrderUID, p.Order)
+}
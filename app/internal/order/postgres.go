@@ -6,81 +6,131 @@ import (
 	"WBL0/app/internal/model"
 	"WBL0/app/pkg/logger"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"strings"
 	"time"
 )
 
 var _ Storage = &OrderStorage{}
 
+// orderColumns selects an order from "order" o with its item IDs collected from order_items in position order.
+const orderColumns = `o.order_uid, o.track_number, o.entry, o.delivery, o.payment,
+			    COALESCE((SELECT array_agg(oi.item_id ORDER BY oi.position) FROM order_items oi WHERE oi.order_uid = o.order_uid), '{}'),
+			    o.locale, o.internal_signature, o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard`
+
 type OrderStorage struct {
 	log            logger.Logger
-	conn           *pgx.Conn
+	pool           *pgxpool.Pool
 	requestTimeout time.Duration
-	cache          *cache.Cache
 }
 
-func NewStorage(storage *pgx.Conn, requestTimeout int, cache *cache.Cache) Storage {
+func NewStorage(storage *pgxpool.Pool, requestTimeout int) Storage {
 	return &OrderStorage{
 		log:            logger.GetLogger(),
-		conn:           storage,
+		pool:           storage,
 		requestTimeout: time.Duration(requestTimeout) * time.Second,
-		cache:          cache,
 	}
 }
 
-func (d *OrderStorage) Create(order *CreateOrderDTO) (*CreateOrderDTO, error) {
-	d.log.Info("POSTGRES: CREATE ORDER")
+func (d *OrderStorage) Create(ctx context.Context, tx pgx.Tx, order *CreateOrderDTO) (*CreateOrderDTO, error) {
+	log := logger.FromContext(ctx, d.log)
+	log.Info("POSTGRES: CREATE ORDER")
 
-	ctx, cancel := context.WithTimeout(context.Background(), d.requestTimeout)
+	ctx, cancel := context.WithTimeout(ctx, d.requestTimeout)
 	defer cancel()
 
-	_, err := d.conn.Exec(ctx,
-		`INSERT INTO "order" (order_uid, track_number, entry, delivery, payment, items, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
-         VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14)`,
-		order.OrderUID, order.TrackNumber, order.Entry, order.Delivery, order.Payment, order.Items, order.Locale, order.InternalSignature, order.CustomerID, order.DeliveryService, order.ShardKey, order.SMID, order.DateCreated, order.OofShard)
+	_, err := tx.Exec(ctx,
+		`INSERT INTO "order" (order_uid, track_number, entry, delivery, payment, locale, internal_signature, customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
+         VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)`,
+		order.OrderUID, order.TrackNumber, order.Entry, order.Delivery, order.Payment, order.Locale, order.InternalSignature, order.CustomerID, order.DeliveryService, order.ShardKey, order.SMID, order.DateCreated, order.OofShard)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute create order query: %v", err)
 	}
 
-	modelOrder := &model.Order{
-		OrderUID:          order.OrderUID,
-		TrackNumber:       order.TrackNumber,
-		Entry:             order.Entry,
-		Delivery:          order.Delivery,
-		Payment:           order.Payment,
-		Items:             order.Items,
-		Locale:            order.Locale,
-		InternalSignature: order.InternalSignature,
-		CustomerID:        order.CustomerID,
-		DeliveryService:   order.DeliveryService,
-		ShardKey:          order.ShardKey,
-		SMID:              order.SMID,
-		DateCreated:       order.DateCreated,
-		OofShard:          order.OofShard,
+	_, err = tx.Exec(ctx,
+		`INSERT INTO order_items (order_uid, item_id, position)
+		 SELECT $1, u.id, u.position FROM unnest($2::bigint[]) WITH ORDINALITY AS u(id, position)`,
+		order.OrderUID, order.Items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute create order items query: %v", err)
 	}
+	return order, nil
+}
 
-	d.cache.Orders[order.OrderUID] = modelOrder
+func (d *OrderStorage) FindById(ctx context.Context, uid string) (*CreateOrderDTO, error) {
+	log := logger.FromContext(ctx, d.log)
+	log.Info("POSTGRES: GET ORDER BY ID")
 
-	fmt.Println("\n\nCache after order creation:")
-	for key, value := range d.cache.Orders {
-		fmt.Printf("Key: %s, Value: %+v\n", key, value)
-	}
+	ctx, cancel := context.WithTimeout(ctx, d.requestTimeout)
+	defer cancel()
+
+	row := d.pool.QueryRow(ctx,
+		`SELECT `+orderColumns+`
+			 FROM "order" o
+			 WHERE o.order_uid = $1`, uid)
+	order := &CreateOrderDTO{}
 
+	err := row.Scan(
+		&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Delivery, &order.Payment, &order.Items,
+		&order.Locale, &order.InternalSignature, &order.CustomerID, &order.DeliveryService,
+		&order.ShardKey, &order.SMID, &order.DateCreated, &order.OofShard)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperror.ErrEmptyString
+		}
+		err = fmt.Errorf("failed to execute find order by id query: %v", err)
+		return nil, err
+	}
 	return order, nil
 }
 
-func (d *OrderStorage) FindById(uid string) (*CreateOrderDTO, error) {
-	d.log.Info("POSTGRES: GET ORDER BY ID")
+func (d *OrderStorage) FindFullByIdTx(ctx context.Context, tx pgx.Tx, uid string) (*Order, error) {
+	log := logger.FromContext(ctx, d.log)
+	log.Info("POSTGRES: GET FULL ORDER BY ID IN TRANSACTION")
+
+	ctx, cancel := context.WithTimeout(ctx, d.requestTimeout)
+	defer cancel()
+
+	parts, err := findPartsById(ctx, tx, uid)
+	if err != nil {
+		return nil, err
+	}
+	return parts.Full(), nil
+}
+
+func (d *OrderStorage) FindPartsById(ctx context.Context, uid string) (*Parts, error) {
+	log := logger.FromContext(ctx, d.log)
+	log.Info("POSTGRES: GET ORDER PARTS BY ID")
+
+	ctx, cancel := context.WithTimeout(ctx, d.requestTimeout)
+	defer cancel()
+
+	return findPartsById(ctx, d.pool, uid)
+}
+
+// LockById serializes ingestion of the same order_uid until the end of tx
+// and returns the already stored order, if any.
+func (d *OrderStorage) LockById(ctx context.Context, tx pgx.Tx, uid string) (*CreateOrderDTO, error) {
+	log := logger.FromContext(ctx, d.log)
+	log.Info("POSTGRES: LOCK ORDER BY ID")
 
-	ctx, cancel := context.WithTimeout(context.Background(), d.requestTimeout)
+	ctx, cancel := context.WithTimeout(ctx, d.requestTimeout)
 	defer cancel()
 
-	row := d.conn.QueryRow(ctx,
-		`SELECT * FROM "order"
-			 WHERE order_uid = $1`, uid)
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, uid); err != nil {
+		return nil, fmt.Errorf("failed to execute lock order query: %v", err)
+	}
+
+	row := tx.QueryRow(ctx,
+		`SELECT `+orderColumns+`
+			 FROM "order" o
+			 WHERE o.order_uid = $1
+			 FOR UPDATE OF o`, uid)
 	order := &CreateOrderDTO{}
 
 	err := row.Scan(
@@ -91,15 +141,193 @@ func (d *OrderStorage) FindById(uid string) (*CreateOrderDTO, error) {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, apperror.ErrEmptyString
 		}
-		err = fmt.Errorf("failed to execute find order by id query: %v", err)
-		return nil, err
+		return nil, fmt.Errorf("failed to execute find order for update query: %v", err)
 	}
 	return order, nil
 }
 
-func CacheForOrder(dbConn *pgx.Conn, cache *cache.Cache) error {
+// Delete removes the order together with its delivery, payment and items.
+func (d *OrderStorage) Delete(ctx context.Context, tx pgx.Tx, uid string) error {
+	log := logger.FromContext(ctx, d.log)
+	log.Info("POSTGRES: DELETE ORDER")
+
+	ctx, cancel := context.WithTimeout(ctx, d.requestTimeout)
+	defer cancel()
+
+	_, err := tx.Exec(ctx,
+		`WITH i AS (
+			     SELECT item_id FROM order_items WHERE order_uid = $1
+			 ), o AS (
+			     DELETE FROM "order" WHERE order_uid = $1
+			     RETURNING delivery, payment
+			 ), d AS (
+			     DELETE FROM Delivery WHERE id IN (SELECT delivery FROM o)
+			 ), p AS (
+			     DELETE FROM Payment WHERE id IN (SELECT payment FROM o)
+			 )
+			 DELETE FROM Item WHERE id IN (SELECT item_id FROM i)`, uid)
+	if err != nil {
+		return fmt.Errorf("failed to execute delete order query: %v", err)
+	}
+	return nil
+}
+
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// partsQuery selects an order with its delivery, payment and items in position order.
+const partsQuery = `SELECT o.order_uid, o.track_number, o.entry,
+			    d.id, d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
+			    p.id, p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt,
+			    p.bank, p.delivery_cost, p.goods_total, p.custom_fee,
+			    COALESCE(i.items, '[]'::json),
+			    o.locale, o.internal_signature, o.customer_id, o.delivery_service,
+			    o.shardkey, o.sm_id, o.date_created, o.oof_shard
+			 FROM "order" o
+			 JOIN Delivery d ON d.id = o.delivery
+			 JOIN Payment p ON p.id = o.payment
+			 LEFT JOIN LATERAL (
+			     SELECT json_agg(it ORDER BY u.position) AS items
+			     FROM order_items u
+			     JOIN Item it ON it.id = u.item_id
+			     WHERE u.order_uid = o.order_uid
+			 ) i ON true`
+
+func findPartsById(ctx context.Context, q querier, uid string) (*Parts, error) {
+	parts, err := scanParts(q.QueryRow(ctx, partsQuery+`
+			 WHERE o.order_uid = $1`, uid))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperror.ErrEmptyString
+		}
+		return nil, fmt.Errorf("failed to execute find full order by id query: %v", err)
+	}
+	return parts, nil
+}
+
+func scanParts(row pgx.Row) (*Parts, error) {
+	parts := &Parts{Order: &model.Order{}, Delivery: &model.Delivery{}, Payment: &model.Payment{}}
+	o, d, p := parts.Order, parts.Delivery, parts.Payment
+	var items []byte
+
+	err := row.Scan(
+		&o.OrderUID, &o.TrackNumber, &o.Entry,
+		&d.ID, &d.Name, &d.Phone, &d.Zip, &d.City, &d.Address, &d.Region, &d.Email,
+		&p.ID, &p.Transaction, &p.RequestID, &p.Currency, &p.Provider,
+		&p.Amount, &p.PaymentDt, &p.Bank, &p.DeliveryCost, &p.GoodsTotal, &p.CustomFee,
+		&items,
+		&o.Locale, &o.InternalSignature, &o.CustomerID, &o.DeliveryService,
+		&o.ShardKey, &o.SMID, &o.DateCreated, &o.OofShard)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(items, &parts.Items); err != nil {
+		return nil, fmt.Errorf("failed to decode order items: %v", err)
+	}
+	o.Delivery, o.Payment = d.ID, p.ID
+	o.Items = make([]int64, 0, len(parts.Items))
+	for _, i := range parts.Items {
+		o.Items = append(o.Items, i.ID)
+	}
+	return parts, nil
+}
+
+func (d *OrderStorage) FindAll(ctx context.Context, q *ListQuery) ([]*CreateOrderDTO, error) {
+	log := logger.FromContext(ctx, d.log)
+	log.Info("POSTGRES: GET ORDERS")
+
+	ctx, cancel := context.WithTimeout(ctx, d.requestTimeout)
+	defer cancel()
+
+	var (
+		conds []string
+		args  []interface{}
+	)
+	where := func(cond string, values ...interface{}) {
+		placeholders := make([]interface{}, 0, len(values))
+		for _, v := range values {
+			args = append(args, v)
+			placeholders = append(placeholders, len(args))
+		}
+		conds = append(conds, fmt.Sprintf(cond, placeholders...))
+	}
+
+	f := q.Filter
+	if f.CustomerID != "" {
+		where("customer_id = $%d", f.CustomerID)
+	}
+	if f.TrackNumber != "" {
+		where("track_number = $%d", f.TrackNumber)
+	}
+	if f.Track != "" {
+		where(`o.order_uid IN (
+			     SELECT order_uid FROM "order" WHERE track_number = $%d
+			     UNION
+			     SELECT oi.order_uid FROM order_items oi JOIN Item it ON it.id = oi.item_id WHERE it.track_number = $%d
+			 )`, f.Track, f.Track)
+	}
+	if f.DeliveryService != "" {
+		where("delivery_service = $%d", f.DeliveryService)
+	}
+	if f.Entry != "" {
+		where("entry = $%d", f.Entry)
+	}
+	if f.Locale != "" {
+		where("locale = $%d", f.Locale)
+	}
+	if f.DateFrom != nil {
+		where("date_created::timestamptz >= $%d", *f.DateFrom)
+	}
+	if f.DateTo != nil {
+		where("date_created::timestamptz <= $%d", *f.DateTo)
+	}
+
+	// The sort column comes from a fixed set checked by ParseListQuery
+	column, direction, op := q.Sort, "ASC", ">"
+	if q.Desc {
+		direction, op = "DESC", "<"
+	}
+	if q.After != nil {
+		where(`(`+column+` COLLATE "C", order_uid COLLATE "C") `+op+` ($%d, $%d)`, q.After.Value, q.After.UID)
+	}
+
+	sql := `SELECT ` + orderColumns + `
+			 FROM "order" o`
+	if len(conds) > 0 {
+		sql += " WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, q.Limit+1)
+	sql += fmt.Sprintf(` ORDER BY %s COLLATE "C" %s, order_uid COLLATE "C" %s LIMIT $%d`, column, direction, direction, len(args))
+
+	rows, err := d.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute find orders query: %v", err)
+	}
+	defer rows.Close()
+
+	orders := []*CreateOrderDTO{}
+	for rows.Next() {
+		order := &CreateOrderDTO{}
+		err = rows.Scan(
+			&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Delivery, &order.Payment, &order.Items,
+			&order.Locale, &order.InternalSignature, &order.CustomerID, &order.DeliveryService,
+			&order.ShardKey, &order.SMID, &order.DateCreated, &order.OofShard)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %v", err)
+		}
+		orders = append(orders, order)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read orders: %v", err)
+	}
+	return orders, nil
+}
+
+func CacheForOrder(dbPool *pgxpool.Pool, cache *cache.Cache) error {
 
-	rows, err := dbConn.Query(context.Background(), `SELECT * FROM "order"`)
+	rows, err := dbPool.Query(context.Background(), `SELECT `+orderColumns+` FROM "order" o`)
 	if err != nil {
 		return err
 	}
@@ -114,23 +342,66 @@ func CacheForOrder(dbConn *pgx.Conn, cache *cache.Cache) error {
 		if err != nil {
 			return err
 		}
-		modelOrder := &model.Order{
-			OrderUID:          order.OrderUID,
-			TrackNumber:       order.TrackNumber,
-			Entry:             order.Entry,
-			Delivery:          order.Delivery,
-			Payment:           order.Payment,
-			Items:             order.Items,
-			Locale:            order.Locale,
-			InternalSignature: order.InternalSignature,
-			CustomerID:        order.CustomerID,
-			DeliveryService:   order.DeliveryService,
-			ShardKey:          order.ShardKey,
-			SMID:              order.SMID,
-			DateCreated:       order.DateCreated,
-			OofShard:          order.OofShard,
+		cache.Orders().Set(order.OrderUID, order.ToModel())
+	}
+	return rows.Err()
+}
+
+// CacheForRecentOrders loads the limit most recent orders with their delivery, payment and items,
+// for a bounded cache that cannot hold every row.
+func CacheForRecentOrders(dbPool *pgxpool.Pool, cache *cache.Cache, limit int) error {
+
+	rows, err := dbPool.Query(context.Background(), partsQuery+`
+			 WHERE o.order_uid IN (
+			     SELECT order_uid FROM "order"
+			     ORDER BY date_created COLLATE "C" DESC, order_uid COLLATE "C" DESC
+			     LIMIT $1
+			 )`, limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		parts, err := scanParts(rows)
+		if err != nil {
+			return err
 		}
-		cache.Orders[order.OrderUID] = modelOrder
+		parts.AddToCache(cache)
 	}
-	return nil
+	return rows.Err()
+}
+
+// CacheForOrdersAfter loads the orders whose delivery ID is above watermark, oldest first, and returns
+// how many it loaded. A positive limit loads only the limit most recent of them, for a bounded cache.
+func CacheForOrdersAfter(dbPool *pgxpool.Pool, cache *cache.Cache, watermark int64, limit int) (int, error) {
+	// LIMIT NULL is no limit
+	var maxOrders interface{}
+	if limit > 0 {
+		maxOrders = limit
+	}
+
+	rows, err := dbPool.Query(context.Background(), partsQuery+`
+			 WHERE o.order_uid IN (
+			     SELECT order_uid FROM "order"
+			     WHERE delivery > $1
+			     ORDER BY delivery DESC
+			     LIMIT $2
+			 )
+			 ORDER BY o.delivery`, watermark, maxOrders)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	loaded := 0
+	for rows.Next() {
+		parts, err := scanParts(rows)
+		if err != nil {
+			return loaded, err
+		}
+		parts.SetInCache(cache)
+		loaded++
+	}
+	return loaded, rows.Err()
 }
@@ -0,0 +1,51 @@
+package order
+
+import (
+	"WBL0/app/internal/cache"
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestCacheForOrdersAfter(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	for id := 1; id <= 5; id++ {
+		_, err := pool.Exec(ctx, `INSERT INTO Delivery (id) VALUES ($1)`, id)
+		if err == nil {
+			_, err = pool.Exec(ctx, `INSERT INTO Payment (id) VALUES ($1)`, id)
+		}
+		if err == nil {
+			_, err = pool.Exec(ctx, `INSERT INTO "order" (order_uid, delivery, payment) VALUES ($1, $2, $2)`, fmt.Sprint("o", id), id)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		watermark int64
+		limit     int
+		want      []string
+	}{
+		{watermark: 2, want: []string{"o3", "o4", "o5"}},
+		{watermark: 0, limit: 2, want: []string{"o4", "o5"}},
+		{watermark: 5},
+	}
+	for _, c := range cases {
+		cached := cache.NewCache()
+		loaded, err := CacheForOrdersAfter(pool, cached, c.watermark, c.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded != len(c.want) || cached.Orders().Len() != len(c.want) {
+			t.Errorf("watermark %d, limit %d: loaded %d orders, want %v", c.watermark, c.limit, loaded, c.want)
+		}
+		for _, uid := range c.want {
+			if _, ok := cached.Orders().Get(uid); !ok {
+				t.Errorf("watermark %d, limit %d: %s is not cached", c.watermark, c.limit, uid)
+			}
+		}
+	}
+}
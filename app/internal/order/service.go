@@ -2,14 +2,21 @@ package order
 
 import (
 	"WBL0/app/internal/apperror"
+	"WBL0/app/internal/model"
 	"WBL0/app/pkg/logger"
 	"context"
 	"errors"
+	"github.com/jackc/pgx/v4"
 )
 
 type Service interface {
-	Create(ctx context.Context, order *CreateOrderDTO) (*CreateOrderDTO, error)
+	Create(ctx context.Context, tx pgx.Tx, order *CreateOrderDTO) (*CreateOrderDTO, error)
 	GetById(ctx context.Context, uid string) (*CreateOrderDTO, error)
+	GetFullByIdTx(ctx context.Context, tx pgx.Tx, uid string) (*Order, error)
+	GetPartsById(ctx context.Context, uid string) (*Parts, error)
+	LockById(ctx context.Context, tx pgx.Tx, uid string) (*CreateOrderDTO, error)
+	Delete(ctx context.Context, tx pgx.Tx, uid string) error
+	GetAll(ctx context.Context, q *ListQuery) (*ListResult, error)
 }
 
 type service struct {
@@ -23,8 +30,9 @@ func NewService(storage Storage, log logger.Logger) Service {
 		storage: storage,
 	}
 }
-func (s *service) Create(ctx context.Context, input *CreateOrderDTO) (*CreateOrderDTO, error) {
-	s.log.Info("SERVICE: CREATE ORDER")
+func (s *service) Create(ctx context.Context, tx pgx.Tx, input *CreateOrderDTO) (*CreateOrderDTO, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: CREATE ORDER")
 
 	o := CreateOrderDTO{
 		OrderUID:          input.OrderUID,
@@ -43,7 +51,7 @@ func (s *service) Create(ctx context.Context, input *CreateOrderDTO) (*CreateOrd
 		OofShard:          input.OofShard,
 	}
 
-	order, err := s.storage.Create(&o)
+	order, err := s.storage.Create(ctx, tx, &o)
 	if err != nil {
 		return nil, err
 	}
@@ -51,15 +59,69 @@ func (s *service) Create(ctx context.Context, input *CreateOrderDTO) (*CreateOrd
 }
 
 func (s *service) GetById(ctx context.Context, uid string) (*CreateOrderDTO, error) {
-	s.log.Info("SERVICE: GET ORDER BY ID")
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: GET ORDER BY ID")
 
-	order, err := s.storage.FindById(uid)
+	order, err := s.storage.FindById(ctx, uid)
 	if err != nil {
 		if errors.Is(err, apperror.ErrEmptyString) {
 			return nil, err
 		}
-		s.log.Warn("cannot find order by id:", err)
+		log.Warn("cannot find order by id:", err)
 		return nil, err
 	}
 	return order, nil
 }
+
+func (s *service) GetFullByIdTx(ctx context.Context, tx pgx.Tx, uid string) (*Order, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: GET FULL ORDER BY ID IN TRANSACTION")
+
+	return s.storage.FindFullByIdTx(ctx, tx, uid)
+}
+
+func (s *service) GetPartsById(ctx context.Context, uid string) (*Parts, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: GET ORDER PARTS BY ID")
+
+	parts, err := s.storage.FindPartsById(ctx, uid)
+	if err != nil {
+		if errors.Is(err, apperror.ErrEmptyString) {
+			return nil, err
+		}
+		log.Warn("cannot find order parts by id:", err)
+		return nil, err
+	}
+	return parts, nil
+}
+
+func (s *service) LockById(ctx context.Context, tx pgx.Tx, uid string) (*CreateOrderDTO, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: LOCK ORDER BY ID")
+
+	return s.storage.LockById(ctx, tx, uid)
+}
+
+func (s *service) Delete(ctx context.Context, tx pgx.Tx, uid string) error {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: DELETE ORDER")
+
+	return s.storage.Delete(ctx, tx, uid)
+}
+
+func (s *service) GetAll(ctx context.Context, q *ListQuery) (*ListResult, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: GET ORDERS")
+
+	orders, err := s.storage.FindAll(ctx, q)
+	if err != nil {
+		log.Warn("cannot find orders:", err)
+		return nil, err
+	}
+
+	models := make([]*model.Order, 0, len(orders))
+	for _, o := range orders {
+		models = append(models, o.ToModel())
+	}
+	return q.Page(models), nil
+}
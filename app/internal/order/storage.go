@@ -1,6 +1,16 @@
 package order
 
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+)
+
 type Storage interface {
-	Create(order *CreateOrderDTO) (*CreateOrderDTO, error)
-	FindById(uid string) (*CreateOrderDTO, error)
+	Create(ctx context.Context, tx pgx.Tx, order *CreateOrderDTO) (*CreateOrderDTO, error)
+	FindById(ctx context.Context, uid string) (*CreateOrderDTO, error)
+	FindFullByIdTx(ctx context.Context, tx pgx.Tx, uid string) (*Order, error)
+	FindPartsById(ctx context.Context, uid string) (*Parts, error)
+	LockById(ctx context.Context, tx pgx.Tx, uid string) (*CreateOrderDTO, error)
+	Delete(ctx context.Context, tx pgx.Tx, uid string) error
+	FindAll(ctx context.Context, q *ListQuery) ([]*CreateOrderDTO, error)
 }
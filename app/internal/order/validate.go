@@ -0,0 +1,77 @@
+package order
+
+import (
+	"WBL0/app/internal/apperror"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Validate checks an incoming order before anything is published or written.
+// It returns *apperror.ValidationError listing every invalid field.
+func (o *Order) Validate() error {
+	v := &apperror.ValidationError{}
+
+	v.Check(notBlank(o.OrderUID), "order_uid", "must not be empty")
+	v.Check(notBlank(o.TrackNumber), "track_number", "must not be empty")
+	v.Check(notBlank(o.Entry), "entry", "must not be empty")
+	v.Check(notBlank(o.Locale), "locale", "must not be empty")
+	v.Check(notBlank(o.CustomerID), "customer_id", "must not be empty")
+	v.Check(notBlank(o.DeliveryService), "delivery_service", "must not be empty")
+	v.Check(o.SMID >= 0, "sm_id", "must not be negative")
+	if _, err := time.Parse(time.RFC3339, o.DateCreated); err != nil {
+		v.Add("date_created", "must be an RFC 3339 timestamp")
+	}
+
+	o.Delivery.validate(v, "delivery")
+	o.Payment.validate(v, "payment")
+
+	if len(o.Items) == 0 {
+		v.Add("items", "must contain at least one item")
+	}
+	for i := range o.Items {
+		o.Items[i].validate(v, fmt.Sprintf("items[%d]", i))
+	}
+
+	return v.Err()
+}
+
+func (d *Delivery) validate(v *apperror.ValidationError, prefix string) {
+	v.Check(notBlank(d.Name), prefix+".name", "must not be empty")
+	v.Check(notBlank(d.Phone), prefix+".phone", "must not be empty")
+	v.Check(notBlank(d.City), prefix+".city", "must not be empty")
+	v.Check(notBlank(d.Address), prefix+".address", "must not be empty")
+	if d.Email != "" {
+		if _, err := mail.ParseAddress(d.Email); err != nil {
+			v.Add(prefix+".email", "must be a valid email address")
+		}
+	}
+}
+
+func (p *Payment) validate(v *apperror.ValidationError, prefix string) {
+	v.Check(notBlank(p.Transaction), prefix+".transaction", "must not be empty")
+	v.Check(len(p.Currency) == 3, prefix+".currency", "must be a three-letter currency code")
+	v.Check(notBlank(p.Provider), prefix+".provider", "must not be empty")
+	v.Check(p.Amount >= 0, prefix+".amount", "must not be negative")
+	v.Check(p.PaymentDt > 0, prefix+".payment_dt", "must be a positive unix timestamp")
+	v.Check(p.DeliveryCost >= 0, prefix+".delivery_cost", "must not be negative")
+	v.Check(p.GoodsTotal >= 0, prefix+".goods_total", "must not be negative")
+	v.Check(p.CustomFee >= 0, prefix+".custom_fee", "must not be negative")
+}
+
+func (i *Item) validate(v *apperror.ValidationError, prefix string) {
+	v.Check(i.ChrtID > 0, prefix+".chrt_id", "must be positive")
+	v.Check(notBlank(i.TrackNumber), prefix+".track_number", "must not be empty")
+	v.Check(i.Price >= 0, prefix+".price", "must not be negative")
+	v.Check(notBlank(i.RID), prefix+".rid", "must not be empty")
+	v.Check(notBlank(i.Name), prefix+".name", "must not be empty")
+	v.Check(i.Sale >= 0 && i.Sale <= 100, prefix+".sale", "must be a percentage between 0 and 100")
+	v.Check(i.TotalPrice >= 0, prefix+".total_price", "must not be negative")
+	v.Check(i.NmID > 0, prefix+".nm_id", "must be positive")
+	v.Check(i.Status >= 0, prefix+".status", "must not be negative")
+}
+
+func notBlank(s string) bool {
+	return strings.TrimSpace(s) != ""
+}
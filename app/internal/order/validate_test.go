@@ -0,0 +1,105 @@
+package order
+
+import (
+	"WBL0/app/internal/apperror"
+	"errors"
+	"testing"
+)
+
+// sampleOrder returns the example order from the README.
+func sampleOrder() *Order {
+	return &Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: Payment{
+			Transaction:  "b563feb7b2b84b6test",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			RID:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SMID:            99,
+		DateCreated:     "2021-11-26T06:22:19Z",
+		OofShard:        "1",
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := sampleOrder().Validate(); err != nil {
+		t.Fatalf("expected the README sample to be valid: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		change func(o *Order)
+		fields []string
+	}{
+		{"empty order_uid", func(o *Order) { o.OrderUID = "" }, []string{"order_uid"}},
+		{"blank order_uid", func(o *Order) { o.OrderUID = "  " }, []string{"order_uid"}},
+		{"missing items", func(o *Order) { o.Items = nil }, []string{"items"}},
+		{"negative price", func(o *Order) { o.Items[0].Price = -1 }, []string{"items[0].price"}},
+		{"sale above 100", func(o *Order) { o.Items[0].Sale = 101 }, []string{"items[0].sale"}},
+		{"negative sale", func(o *Order) { o.Items[0].Sale = -1 }, []string{"items[0].sale"}},
+		{"date without time zone", func(o *Order) { o.DateCreated = "2021-11-26 06:22:19" }, []string{"date_created"}},
+		{"empty date", func(o *Order) { o.DateCreated = "" }, []string{"date_created"}},
+		{"invalid email", func(o *Order) { o.Delivery.Email = "test" }, []string{"delivery.email"}},
+		{"invalid currency", func(o *Order) { o.Payment.Currency = "US" }, []string{"payment.currency"}},
+		{"second item", func(o *Order) {
+			o.Items = append(o.Items, o.Items[0])
+			o.Items[1].ChrtID = 0
+		}, []string{"items[1].chrt_id"}},
+		{"several fields", func(o *Order) {
+			o.OrderUID = ""
+			o.Items[0].Sale = 150
+		}, []string{"order_uid", "items[0].sale"}},
+	}
+	for _, c := range cases {
+		o := sampleOrder()
+		c.change(o)
+
+		var verr *apperror.ValidationError
+		if err := o.Validate(); !errors.As(err, &verr) {
+			t.Errorf("%s: expected a validation error, got %v", c.name, err)
+			continue
+		}
+		if len(verr.Fields) != len(c.fields) {
+			t.Errorf("%s: got fields %+v, want %v", c.name, verr.Fields, c.fields)
+			continue
+		}
+		for i, f := range verr.Fields {
+			if f.Field != c.fields[i] {
+				t.Errorf("%s: got field %q, want %q", c.name, f.Field, c.fields[i])
+			}
+		}
+	}
+}
@@ -4,10 +4,10 @@ import (
 	"WBL0/app/internal/apperror"
 	"WBL0/app/internal/cache"
 	"WBL0/app/internal/handler"
+	"WBL0/app/internal/metrics"
 	"WBL0/app/internal/response"
 	"WBL0/app/pkg/logger"
 	"errors"
-	"github.com/julienschmidt/httprouter"
 	"net/http"
 )
 
@@ -29,24 +29,32 @@ func NewHandler(log logger.Logger, paymentService Service, cache *cache.Cache) h
 	}
 }
 
-func (h *Handler) Register(router *httprouter.Router) {
+func (h *Handler) Permissions() handler.Permissions {
+	return handler.Permissions{
+		handler.Route(http.MethodGet, paymentURL): handler.PermissionRead,
+	}
+}
+
+func (h *Handler) Register(router handler.Router) {
 	router.HandlerFunc(http.MethodGet, paymentURL, h.GetPaymentURLById)
 }
 
 func (h *Handler) GetPaymentURLById(w http.ResponseWriter, r *http.Request) {
-	h.log.Infof("HANDLER: GET PAYMENT BY ID")
+	log := logger.FromContext(r.Context(), h.log)
+	log.Infof("HANDLER: GET PAYMENT BY ID")
 
 	id, err := handler.ReadIdParam64(r)
 
-	h.log.Info("Input: ", id)
+	log.Info("Input: ", id)
 	if err != nil {
-		response.BadRequest(w, err.Error(), "")
+		response.BadRequest(w, r, err.Error(), "")
 		return
 	}
 
-	cachePayment, ok := h.cache.Payments[id]
+	cachePayment, ok := h.cache.Payments().Get(id)
+	metrics.CacheLookup(metrics.EntityPayment, ok)
 	if ok {
-		h.log.Info("GOT PAYMENT FROM CACHE BY ID")
+		log.Info("GOT PAYMENT FROM CACHE BY ID")
 		response.JSON(w, http.StatusOK, cachePayment)
 		return
 	}
@@ -54,13 +62,15 @@ func (h *Handler) GetPaymentURLById(w http.ResponseWriter, r *http.Request) {
 	payment, err := h.paymentService.GetById(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, apperror.ErrEmptyString) {
-			response.NotFound(w)
+			response.NotFound(w, r)
 			return
 		}
-		response.InternalError(w, err.Error(), "")
+		response.InternalError(w, r, err.Error(), "")
 		return
 	}
 
-	h.log.Info("GOT PAYMENT BY ID")
+	h.cache.Payments().Add(payment.ID, payment.ToModel())
+
+	log.Info("GOT PAYMENT BY ID")
 	response.JSON(w, http.StatusOK, payment)
 }
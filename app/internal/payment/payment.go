@@ -1,5 +1,7 @@
 package payment
 
+import "WBL0/app/internal/model"
+
 type Payment struct {
 	ID           int64  `json:"id"`
 	Transaction  string `json:"transaction"`
@@ -26,3 +28,19 @@ type CreatePaymentDTO struct {
 	GoodsTotal   int    `json:"goods_total"`
 	CustomFee    int    `json:"custom_fee"`
 }
+
+func (p *Payment) ToModel() *model.Payment {
+	return &model.Payment{
+		ID:           p.ID,
+		Transaction:  p.Transaction,
+		RequestID:    p.RequestID,
+		Currency:     p.Currency,
+		Provider:     p.Provider,
+		Amount:       p.Amount,
+		PaymentDt:    p.PaymentDt,
+		Bank:         p.Bank,
+		DeliveryCost: p.DeliveryCost,
+		GoodsTotal:   p.GoodsTotal,
+		CustomFee:    p.CustomFee,
+	}
+}
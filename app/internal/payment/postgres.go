@@ -3,12 +3,12 @@ package payment
 import (
 	"WBL0/app/internal/apperror"
 	"WBL0/app/internal/cache"
-	"WBL0/app/internal/model"
 	"WBL0/app/pkg/logger"
 	"context"
 	"errors"
 	"fmt"
 	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"time"
 )
 
@@ -16,27 +16,26 @@ var _ Storage = &PaymentStorage{}
 
 type PaymentStorage struct {
 	log            logger.Logger
-	conn           *pgx.Conn
+	pool           *pgxpool.Pool
 	requestTimeout time.Duration
-	cache          *cache.Cache
 }
 
-func NewStorage(storage *pgx.Conn, requestTimeout int, cache *cache.Cache) Storage {
+func NewStorage(storage *pgxpool.Pool, requestTimeout int) Storage {
 	return &PaymentStorage{
 		log:            logger.GetLogger(),
-		conn:           storage,
+		pool:           storage,
 		requestTimeout: time.Duration(requestTimeout) * time.Second,
-		cache:          cache,
 	}
 }
 
-func (d *PaymentStorage) Create(payment *Payment) (*Payment, error) {
-	d.log.Info("POSTGRES: CREATE PAYMENT")
+func (d *PaymentStorage) Create(ctx context.Context, tx pgx.Tx, payment *Payment) (*Payment, error) {
+	log := logger.FromContext(ctx, d.log)
+	log.Info("POSTGRES: CREATE PAYMENT")
 
-	ctx, cancel := context.WithTimeout(context.Background(), d.requestTimeout)
+	ctx, cancel := context.WithTimeout(ctx, d.requestTimeout)
 	defer cancel()
 
-	row := d.conn.QueryRow(ctx,
+	row := tx.QueryRow(ctx,
 		`INSERT INTO Payment (transaction, request_id, currency, provider, amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
 			 VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) 
 			 RETURNING id`,
@@ -47,38 +46,17 @@ func (d *PaymentStorage) Create(payment *Payment) (*Payment, error) {
 		err = fmt.Errorf("failed to execute create payment query: %v", err)
 		return nil, err
 	}
-
-	modelPayment := &model.Payment{
-		ID:           payment.ID,
-		Transaction:  payment.Transaction,
-		RequestID:    payment.RequestID,
-		Currency:     payment.Currency,
-		Provider:     payment.Provider,
-		Amount:       payment.Amount,
-		PaymentDt:    payment.PaymentDt,
-		Bank:         payment.Bank,
-		DeliveryCost: payment.DeliveryCost,
-		GoodsTotal:   payment.GoodsTotal,
-		CustomFee:    payment.CustomFee,
-	}
-
-	d.cache.Payments[payment.ID] = modelPayment
-
-	fmt.Println("\n\nCache after payment creation:")
-	for key, value := range d.cache.Payments {
-		fmt.Printf("Key: %d, Value: %+v\n", key, value)
-	}
-
 	return payment, nil
 }
 
-func (d *PaymentStorage) FindById(id int64) (*Payment, error) {
-	d.log.Info("POSTGRES: GET PAYMENT BY ID")
+func (d *PaymentStorage) FindById(ctx context.Context, id int64) (*Payment, error) {
+	log := logger.FromContext(ctx, d.log)
+	log.Info("POSTGRES: GET PAYMENT BY ID")
 
-	ctx, cancel := context.WithTimeout(context.Background(), d.requestTimeout)
+	ctx, cancel := context.WithTimeout(ctx, d.requestTimeout)
 	defer cancel()
 
-	row := d.conn.QueryRow(ctx,
+	row := d.pool.QueryRow(ctx,
 		`SELECT * FROM Payment
 			 WHERE id = $1`, id)
 	payment := &Payment{}
@@ -96,9 +74,9 @@ func (d *PaymentStorage) FindById(id int64) (*Payment, error) {
 	}
 	return payment, nil
 }
-func CacheForPayment(dbConn *pgx.Conn, cache *cache.Cache) error {
+func CacheForPayment(dbPool *pgxpool.Pool, cache *cache.Cache) error {
 
-	rows, err := dbConn.Query(context.Background(), `SELECT * FROM Payment`)
+	rows, err := dbPool.Query(context.Background(), `SELECT * FROM Payment`)
 	if err != nil {
 		return err
 	}
@@ -114,21 +92,7 @@ func CacheForPayment(dbConn *pgx.Conn, cache *cache.Cache) error {
 			return err
 		}
 
-		modelPayment := &model.Payment{
-			ID:           payment.ID,
-			Transaction:  payment.Transaction,
-			RequestID:    payment.RequestID,
-			Currency:     payment.Currency,
-			Provider:     payment.Provider,
-			Amount:       payment.Amount,
-			PaymentDt:    payment.PaymentDt,
-			Bank:         payment.Bank,
-			DeliveryCost: payment.DeliveryCost,
-			GoodsTotal:   payment.GoodsTotal,
-			CustomFee:    payment.CustomFee,
-		}
-
-		cache.Payments[payment.ID] = modelPayment
+		cache.Payments().Set(payment.ID, payment.ToModel())
 	}
-	return nil
-}
\ No newline at end of file
+	return rows.Err()
+}
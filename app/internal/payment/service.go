@@ -5,10 +5,11 @@ import (
 	"WBL0/app/pkg/logger"
 	"context"
 	"errors"
+	"github.com/jackc/pgx/v4"
 )
 
 type Service interface {
-	Create(ctx context.Context, delivery *CreatePaymentDTO) (*Payment, error)
+	Create(ctx context.Context, tx pgx.Tx, delivery *CreatePaymentDTO) (*Payment, error)
 	GetById(ctx context.Context, id int64) (*Payment, error)
 }
 
@@ -23,8 +24,9 @@ func NewService(storage Storage, log logger.Logger) Service {
 		storage: storage,
 	}
 }
-func (s *service) Create(ctx context.Context, input *CreatePaymentDTO) (*Payment, error) {
-	s.log.Info("SERVICE: CREATE PAYMENT")
+func (s *service) Create(ctx context.Context, tx pgx.Tx, input *CreatePaymentDTO) (*Payment, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: CREATE PAYMENT")
 
 	d := Payment{
 		Transaction:  input.Transaction,
@@ -39,7 +41,7 @@ func (s *service) Create(ctx context.Context, input *CreatePaymentDTO) (*Payment
 		CustomFee:    input.CustomFee,
 	}
 
-	payment, err := s.storage.Create(&d)
+	payment, err := s.storage.Create(ctx, tx, &d)
 	if err != nil {
 		return nil, err
 	}
@@ -47,14 +49,15 @@ func (s *service) Create(ctx context.Context, input *CreatePaymentDTO) (*Payment
 }
 
 func (s *service) GetById(ctx context.Context, id int64) (*Payment, error) {
-	s.log.Info("SERVICE: GET PAYMENT BY ID")
+	log := logger.FromContext(ctx, s.log)
+	log.Info("SERVICE: GET PAYMENT BY ID")
 
-	payment, err := s.storage.FindById(id)
+	payment, err := s.storage.FindById(ctx, id)
 	if err != nil {
 		if errors.Is(err, apperror.ErrEmptyString) {
 			return nil, err
 		}
-		s.log.Warn("cannot find payment by id:", err)
+		log.Warn("cannot find payment by id:", err)
 		return nil, err
 	}
 	return payment, nil
@@ -1,6 +1,11 @@
 package payment
 
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+)
+
 type Storage interface {
-	Create(payment *Payment) (*Payment, error)
-	FindById(id int64) (*Payment, error)
+	Create(ctx context.Context, tx pgx.Tx, payment *Payment) (*Payment, error)
+	FindById(ctx context.Context, id int64) (*Payment, error)
 }
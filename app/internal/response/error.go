@@ -5,22 +5,47 @@ import (
 	"net/http"
 )
 
-func Error(w http.ResponseWriter, code int, message, developerMessage string) {
+func Error(w http.ResponseWriter, r *http.Request, code int, message, developerMessage string) {
+	if WantsProblem(r) {
+		// NewAppError swaps its arguments, so developerMessage is what the legacy body shows as "message".
+		// The detail carries the same client-facing text and never message, which often holds err.Error().
+		WriteProblem(w, NewProblem(r, code, developerMessage))
+		return
+	}
 	appError := apperror.NewAppError(code, message, developerMessage)
 	JSON(w, code, appError)
 }
-func BadRequest(w http.ResponseWriter, message, developerMessage string) {
-	Error(w, http.StatusBadRequest, message, developerMessage)
+func BadRequest(w http.ResponseWriter, r *http.Request, message, developerMessage string) {
+	Error(w, r, http.StatusBadRequest, message, developerMessage)
 }
 
-func ErrorAuth(w http.ResponseWriter, message, developerMessage string) {
-	Error(w, http.StatusUnauthorized, message, developerMessage)
+func ErrorAuth(w http.ResponseWriter, r *http.Request, message, developerMessage string) {
+	Error(w, r, http.StatusUnauthorized, message, developerMessage)
 }
 
-func NotFound(w http.ResponseWriter) {
+func Forbidden(w http.ResponseWriter, r *http.Request, message, developerMessage string) {
+	Error(w, r, http.StatusForbidden, message, developerMessage)
+}
+
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	if WantsProblem(r) {
+		// NewAppError swaps its arguments, so the readable text of ErrNotFound is in DeveloperMessage
+		WriteProblem(w, NewProblem(r, http.StatusNotFound, apperror.ErrNotFound.DeveloperMessage))
+		return
+	}
 	JSON(w, http.StatusNotFound, apperror.ErrNotFound)
 }
 
-func InternalError(w http.ResponseWriter, message, developerMessage string) {
-	Error(w, http.StatusInternalServerError, message, developerMessage)
+func InternalError(w http.ResponseWriter, r *http.Request, message, developerMessage string) {
+	Error(w, r, http.StatusInternalServerError, message, developerMessage)
+}
+
+func ValidationError(w http.ResponseWriter, r *http.Request, err *apperror.ValidationError) {
+	if WantsProblem(r) {
+		WriteProblem(w, NewValidationProblem(r, err))
+		return
+	}
+	appError := apperror.NewAppError(http.StatusBadRequest, "", "request body contains invalid fields")
+	appError.Fields = err.Fields
+	JSON(w, http.StatusBadRequest, appError)
 }
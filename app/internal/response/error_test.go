@@ -0,0 +1,119 @@
+package response
+
+import (
+	"WBL0/app/internal/apperror"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	internalText = "failed to execute find order query: connection refused"
+	clientText   = "order is not available, try again later"
+)
+
+type legacyBody struct {
+	Message          string                `json:"message"`
+	DeveloperMessage string                `json:"developer_message"`
+	Code             int                   `json:"code"`
+	Fields           []apperror.FieldError `json:"fields"`
+}
+
+// call runs a helper for both the legacy body and problem details.
+func call(t *testing.T, helper func(w http.ResponseWriter, r *http.Request)) (*legacyBody, *Problem, *httptest.ResponseRecorder) {
+	t.Helper()
+
+	legacy := httptest.NewRecorder()
+	helper(legacy, httptest.NewRequest(http.MethodGet, "/order/1", nil))
+	body := &legacyBody{}
+	if err := json.Unmarshal(legacy.Body.Bytes(), body); err != nil {
+		t.Fatalf("invalid legacy body %q: %v", legacy.Body, err)
+	}
+	if ct := legacy.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("unexpected legacy content type %q", ct)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/order/1", nil)
+	r.Header.Set("Accept", ContentTypeProblem)
+	helper(w, r)
+	problem := &Problem{}
+	if err := json.Unmarshal(w.Body.Bytes(), problem); err != nil {
+		t.Fatalf("invalid problem %q: %v", w.Body, err)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != ContentTypeProblem {
+		t.Fatalf("unexpected problem content type %q", ct)
+	}
+	if w.Code != legacy.Code || problem.Status != w.Code || problem.Instance != "/order/1" || problem.Title != http.StatusText(w.Code) {
+		t.Fatalf("problem %+v does not match status %d of the legacy body", problem, legacy.Code)
+	}
+	return body, problem, w
+}
+
+func TestErrorHelpers(t *testing.T) {
+	helpers := map[int]func(w http.ResponseWriter, r *http.Request, message, developerMessage string){
+		http.StatusBadRequest:          BadRequest,
+		http.StatusUnauthorized:        ErrorAuth,
+		http.StatusForbidden:           Forbidden,
+		http.StatusInternalServerError: InternalError,
+	}
+	for code, helper := range helpers {
+		helper := helper
+		body, problem, w := call(t, func(w http.ResponseWriter, r *http.Request) {
+			helper(w, r, internalText, clientText)
+		})
+		if w.Code != code || body.Code != code {
+			t.Errorf("%d: got status %d, body code %d", code, w.Code, body.Code)
+		}
+		if body.Message != clientText || body.DeveloperMessage != internalText {
+			t.Errorf("%d: unexpected legacy body %+v", code, body)
+		}
+		if problem.Detail != body.Message {
+			t.Errorf("%d: problem detail %q differs from the legacy message %q", code, problem.Detail, body.Message)
+		}
+
+		// Without a client-facing text the internal one must not leak into the detail
+		_, problem, _ = call(t, func(w http.ResponseWriter, r *http.Request) {
+			helper(w, r, internalText, "")
+		})
+		if problem.Detail != "" {
+			t.Errorf("%d: problem detail exposes the internal message: %q", code, problem.Detail)
+		}
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	body, problem, w := call(t, NotFound)
+	if w.Code != http.StatusNotFound || body.Code != http.StatusNotFound {
+		t.Fatalf("got status %d, body code %d", w.Code, body.Code)
+	}
+	// The legacy ErrNotFound body keeps its readable text in developer_message, see NotFound
+	if problem.Detail != apperror.ErrNotFound.DeveloperMessage || body.DeveloperMessage != problem.Detail {
+		t.Fatalf("unexpected problem detail %q for legacy body %+v", problem.Detail, body)
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	verr := &apperror.ValidationError{}
+	verr.Add("order_uid", "must not be empty")
+	verr.Add("items", "must not be empty")
+
+	body, problem, w := call(t, func(w http.ResponseWriter, r *http.Request) {
+		ValidationError(w, r, verr)
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	if problem.Detail != body.Message {
+		t.Fatalf("problem detail %q differs from the legacy message %q", problem.Detail, body.Message)
+	}
+	if len(body.Fields) != 2 || len(problem.InvalidParams) != 2 {
+		t.Fatalf("expected both invalid fields, got %+v and %+v", body.Fields, problem.InvalidParams)
+	}
+	for i, f := range body.Fields {
+		if p := problem.InvalidParams[i]; p.Name != f.Field || p.Reason != f.Message {
+			t.Errorf("invalid param %+v differs from field %+v", p, f)
+		}
+	}
+}
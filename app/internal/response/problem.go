@@ -0,0 +1,81 @@
+package response
+
+import (
+	"WBL0/app/internal/apperror"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const ContentTypeProblem = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// InvalidParams is the validation extension, listing every invalid field of the request
+	InvalidParams []InvalidParam `json:"invalid_params,omitempty"`
+}
+
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+func NewProblem(r *http.Request, status int, detail string) *Problem {
+	return &Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	}
+}
+
+func NewValidationProblem(r *http.Request, err *apperror.ValidationError) *Problem {
+	p := NewProblem(r, http.StatusBadRequest, "request body contains invalid fields")
+	for _, f := range err.Fields {
+		p.InvalidParams = append(p.InvalidParams, InvalidParam{Name: f.Field, Reason: f.Message})
+	}
+	return p
+}
+
+func WriteProblem(w http.ResponseWriter, p *Problem) {
+	obj, err := json.Marshal(p)
+	if err != nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentTypeProblem)
+	w.WriteHeader(p.Status)
+	w.Write(obj)
+}
+
+// WantsProblem reports whether the Accept header of r prefers application/problem+json
+// over the legacy AppError body, which stays the default when the header is missing or equal.
+func WantsProblem(r *http.Request) bool {
+	var problemQ, otherQ float64
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if mediaType == ContentTypeProblem {
+			problemQ = q
+		} else if q > otherQ {
+			otherQ = q
+		}
+	}
+	return problemQ > 0 && problemQ > otherQ
+}
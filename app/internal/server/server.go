@@ -1,9 +1,17 @@
 package server
 
 import (
+	"WBL0/app/internal/auth"
 	"WBL0/app/internal/cache"
+	"WBL0/app/internal/consistency"
+	"WBL0/app/internal/deadletter"
 	"WBL0/app/internal/delivery"
+	"WBL0/app/internal/handler"
+	"WBL0/app/internal/health"
+	"WBL0/app/internal/ingest"
 	"WBL0/app/internal/item"
+	"WBL0/app/internal/metrics"
+	"WBL0/app/internal/middleware"
 	"WBL0/app/internal/order"
 	"WBL0/app/internal/payment"
 	"WBL0/app/pkg/config"
@@ -11,15 +19,30 @@ import (
 	nats2 "WBL0/app/pkg/nats"
 	"context"
 	"fmt"
-	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/julienschmidt/httprouter"
 	"github.com/nats-io/nats.go"
 	"github.com/pkg/browser"
 	"log"
+	"net"
 	"net/http"
 	"time"
 )
 
+// uiLookups are the routes public/index.html calls, opened to anonymous callers by http.public_lookups.
+var uiLookups = []string{
+	handler.Route(http.MethodGet, "/order/:id"),
+	handler.Route(http.MethodGet, "/delivery/:id"),
+	handler.Route(http.MethodGet, "/payment/:id"),
+	handler.Route(http.MethodGet, "/item/:id"),
+}
+
+const (
+	UIModeOpenBrowser = "open-browser"
+	UIModeServeUI     = "serve-ui"
+	UIModeAPIOnly     = "api-only"
+)
+
 type Server struct {
 	srv     *http.Server
 	log     *logger.Logger
@@ -29,9 +52,11 @@ type Server struct {
 }
 
 func NewServer(cfg *config.Config, handler *httprouter.Router, log *logger.Logger, cache *cache.Cache) *Server {
+	handler.PanicHandler = middleware.Recover(*log)
+
 	return &Server{
 		srv: &http.Server{
-			Handler:      handler,
+			Handler:      middleware.RequestID(handler, *log),
 			WriteTimeout: time.Duration(cfg.HTTP.WriteTimeout) * time.Second,
 			ReadTimeout:  time.Duration(cfg.HTTP.ReadTimeout) * time.Second,
 			Addr:         fmt.Sprintf("%s:%s", cfg.HTTP.Host, cfg.HTTP.Port),
@@ -43,48 +68,115 @@ func NewServer(cfg *config.Config, handler *httprouter.Router, log *logger.Logge
 	}
 }
 
-func (s *Server) Run(dbConn *pgx.Conn, natsConn *nats.Conn) error {
+func (s *Server) Run(dbPool *pgxpool.Pool, natsConn *nats.Conn) error {
 
 	reqTimeout := s.cfg.PostgreSQL.RequestTimeout
 
-	orderStorage := order.NewStorage(dbConn, reqTimeout, s.cache)
+	authService := auth.NewService(*s.cfg, *s.log)
+	router := auth.NewRouter(middleware.NewAccessLog(metrics.NewRouter(s.handler), *s.log), authService, *s.log)
+	if s.cfg.HTTP.PublicLookups {
+		router.Expose(uiLookups...)
+	}
+	authHandler := auth.NewHandler(*s.log, authService)
+	router.Mount(authHandler)
+	s.log.Info("initialized auth routes")
+
+	orderStorage := order.NewStorage(dbPool, reqTimeout)
 	orderService := order.NewService(orderStorage, *s.log)
-	orderHandler := order.NewHandler(*s.cfg, *s.log, *natsConn, orderService, s.cache)
-	orderHandler.Register(s.handler)
+	// In JetStream mode orders are published through the stream, so POST /order can wait for the stream to store them
+	var js nats.JetStreamContext
+	if s.cfg.NATS.Mode == nats2.ModeJetStream {
+		var err error
+		if js, err = nats2.JetStream(*s.cfg, natsConn); err != nil {
+			return err
+		}
+	}
+	orderHandler := order.NewHandler(*s.cfg, *s.log, natsConn, js, orderService, s.cache)
+	router.Mount(orderHandler)
 	s.log.Info("initialized order routes")
 
-	deliveryStorage := delivery.NewStorage(dbConn, reqTimeout, s.cache)
+	deliveryStorage := delivery.NewStorage(dbPool, reqTimeout)
 	deliveryService := delivery.NewService(deliveryStorage, *s.log)
 	deliveryHandler := delivery.NewHandler(*s.log, deliveryService, s.cache)
-	deliveryHandler.Register(s.handler)
+	router.Mount(deliveryHandler)
 	s.log.Info("initialized delivery routes")
 
-	paymentStorage := payment.NewStorage(dbConn, reqTimeout, s.cache)
+	paymentStorage := payment.NewStorage(dbPool, reqTimeout)
 	paymentService := payment.NewService(paymentStorage, *s.log)
 	paymentHandler := payment.NewHandler(*s.log, paymentService, s.cache)
-	paymentHandler.Register(s.handler)
+	router.Mount(paymentHandler)
 	s.log.Info("initialized payment routes")
 
-	itemStorage := item.NewStorage(dbConn, reqTimeout, s.cache)
+	itemStorage := item.NewStorage(dbPool, reqTimeout)
 	itemService := item.NewService(itemStorage, *s.log)
 	itemHandler := item.NewHandler(*s.log, itemService, s.cache)
-	itemHandler.Register(s.handler)
+	router.Mount(itemHandler)
 	s.log.Info("initialized item routes")
 
-	err := nats2.SubNATS(*s.cfg, *s.log, natsConn, deliveryService, paymentService, itemService, orderService)
-	if err != nil {
-		log.Fatal("cannot subscribe to NATS:", err)
+	flagStorage := consistency.NewStorage(dbPool, reqTimeout)
+	flagService := consistency.NewService(flagStorage, *s.log)
+	flagHandler := consistency.NewHandler(*s.log, flagService)
+	router.Mount(flagHandler)
+	s.log.Info("initialized flagged order routes")
+
+	ingestService := ingest.NewService(*s.cfg, dbPool, s.cache, *s.log, deliveryService, paymentService, itemService, orderService, flagService)
+	go func() {
+		<-s.cache.Loaded()
+		if err := nats2.SubNATS(*s.cfg, *s.log, natsConn, ingestService); err != nil {
+			log.Fatal("cannot subscribe to NATS:", err)
+		}
+		s.log.Info("subscribed to NATS")
+	}()
+
+	// In JetStream mode dead letters are kept in their own stream, so they survive restarts
+	deadLetterStorage := deadletter.NewMemoryStorage(s.cfg.NATS.DeadLetterLimit)
+	if js != nil {
+		deadLetterStorage = deadletter.NewStreamStorage(js, s.cfg.NATS.DeadLetterStream, s.cfg.NATS.DeadLetterSubject, s.cfg.NATS.RequestTimeout)
+	}
+	deadLetterService := deadletter.NewService(*s.cfg, deadLetterStorage, *s.log, natsConn, js)
+	deadLetterHandler := deadletter.NewHandler(*s.log, deadLetterService)
+	router.Mount(deadLetterHandler)
+	s.log.Info("initialized dead-letter routes")
+
+	healthService := health.NewService(dbPool, natsConn, s.cache, *s.log, s.cfg.PostgreSQL.RequestTimeout)
+	healthHandler := health.NewHandler(*s.log, healthService)
+	router.Mount(healthHandler)
+	s.log.Info("initialized health routes")
+
+	metrics.RegisterCache(s.cache)
+	router.Mount(metrics.NewHandler())
+	s.log.Info("initialized metrics route")
+
+	if js == nil {
+		if err := nats2.SubDeadLetter(*s.cfg, *s.log, natsConn, deadLetterService); err != nil {
+			log.Fatal("cannot subscribe to NATS dead-letter subject:", err)
+		}
 	}
 
-	fs := http.FileServer(http.Dir("public"))
-	s.handler.Handler(http.MethodGet, "/", fs)
-	s.handler.Handler(http.MethodGet, "/index.html", fs)
-	err = browser.OpenURL("http://" + s.srv.Addr + "/")
+	switch s.cfg.HTTP.UIMode {
+	case UIModeOpenBrowser, UIModeServeUI:
+		fs := http.FileServer(http.Dir("public"))
+		s.handler.Handler(http.MethodGet, "/", fs)
+		s.handler.Handler(http.MethodGet, "/index.html", fs)
+		s.log.Info("initialized UI routes")
+	case UIModeAPIOnly:
+	default:
+		return fmt.Errorf("unknown UI mode %q", s.cfg.HTTP.UIMode)
+	}
+
+	ln, err := net.Listen("tcp", s.srv.Addr)
 	if err != nil {
 		return err
 	}
 
-	return s.srv.ListenAndServe()
+	// The browser is opened once the server is listening, and a missing display only costs a warning
+	if s.cfg.HTTP.UIMode == UIModeOpenBrowser {
+		if err = browser.OpenURL("http://" + s.srv.Addr + "/"); err != nil {
+			s.log.Warn("cannot open browser, the UI is still served at http://"+s.srv.Addr+"/: ", err)
+		}
+	}
+
+	return s.srv.Serve(ln)
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
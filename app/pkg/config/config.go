@@ -15,12 +15,19 @@ type Config struct {
 		Port         string `yaml:"port" env:"HTTP-PORT"`
 		ReadTimeout  int    `yaml:"read_timeout" env:"HTTP-READ-TIMEOUT"`
 		WriteTimeout int    `yaml:"write_timeout" env:"HTTP-WRITE-TIMEOUT"`
+		UIMode       string `yaml:"ui_mode" env:"UI_MODE" env-default:"open-browser"`
+		// PublicLookups serves the by-id lookups the UI calls without a token
+		PublicLookups bool `yaml:"public_lookups" env:"PUBLIC_LOOKUPS"`
 	} `yaml:"http"`
 	PostgreSQL struct {
 		DSN               string `env:"DATABASE_DSN" env-required:"true"`
 		RequestTimeout    int    `yaml:"request_timeout" env-default:"5"`
 		ConnectionTimeout int    `yaml:"connection_timeout" env-default:"10"`
 		ShutdownTimeout   int    `yaml:"shutdown_timeout" env-default:"5"`
+		MaxConns          int32  `yaml:"max_conns" env-default:"10"`
+		MinConns          int32  `yaml:"min_conns" env-default:"0"`
+		MaxConnIdleTime   int    `yaml:"max_conn_idle_time" env-default:"1800"`
+		HealthCheckPeriod int    `yaml:"health_check_period" env-default:"60"`
 	} `yaml:"postgresql" env-required:"true"`
 	NATS struct {
 		URL               string `env:"NATS_URL" env-required:"true"`
@@ -29,12 +36,52 @@ type Config struct {
 		RequestTimeout    int    `yaml:"request_timeout" env-default:"5"`
 		ConnectionTimeout int    `yaml:"connection_timeout" env-default:"10"`
 		ShutdownTimeout   int    `yaml:"shutdown_timeout" env-default:"5"`
+		Mode              string `yaml:"mode" env:"NATS_MODE" env-default:"core"`
+		Stream            string `yaml:"stream" env:"NATS_STREAM" env-default:"ORDERS"`
+		MaxDeliver        int    `yaml:"max_deliver" env-default:"5"`
+		AckWait           int    `yaml:"ack_wait" env-default:"30"`
+		DeadLetterSubject string `yaml:"dead_letter_subject" env:"NATS_DLQ" env-default:"orders.dlq"`
+		DeadLetterStream  string `yaml:"dead_letter_stream" env:"NATS_DLQ_STREAM" env-default:"ORDERS_DLQ"`
+		DeadLetterLimit   int    `yaml:"dead_letter_limit" env-default:"1000"`
 	} `yaml:"nats" env-required:"true"`
+	Ingest struct {
+		DuplicatePolicy string `yaml:"duplicate_policy" env-default:"skip"`
+		ConsistencyMode string `yaml:"consistency_mode" env-default:"flag"`
+	} `yaml:"ingest"`
+	Cache struct {
+		Mode             string `yaml:"mode" env:"CACHE_MODE" env-default:"full"`
+		MaxEntries       int    `yaml:"max_entries" env-default:"100000"`
+		MaxBytes         int64  `yaml:"max_bytes" env-default:"0"`
+		TTL              int    `yaml:"ttl" env-default:"0"`
+		WarmupOrders     int    `yaml:"warmup_orders" env-default:"10000"`
+		SnapshotFile     string `yaml:"snapshot_file" env:"CACHE_SNAPSHOT_FILE"`
+		SnapshotInterval int    `yaml:"snapshot_interval" env-default:"0"`
+		CatchUpMargin    int64  `yaml:"catch_up_margin" env-default:"1000"`
+	} `yaml:"cache"`
+	Log struct {
+		Level       string            `yaml:"level" env:"LOG_LEVEL" env-default:"info"`
+		Format      string            `yaml:"format" env:"LOG_FORMAT" env-default:"text"`
+		Outputs     []string          `yaml:"outputs" env-default:"stdout"`
+		File        string            `yaml:"file" env-default:"logs/all.log"`
+		MaxSize     int               `yaml:"max_size" env-default:"100"`
+		MaxBackups  int               `yaml:"max_backups" env-default:"5"`
+		MaxAge      int               `yaml:"max_age" env-default:"30"`
+		Compress    bool              `yaml:"compress"`
+		Packages    map[string]string `yaml:"packages"`
+		SampleEvery int               `yaml:"sample_every" env-default:"100"`
+	} `yaml:"log"`
 	JWT struct {
 		AccessExpirationMinutes int16  `yaml:"access_expiration_minutes"`
 		RefreshExpirationDays   int16  `yaml:"refresh_expiration_days"`
-		AccessTokenSecretKey    string `yaml:"access_token_secret_key"`
-		RefreshTokenSecretKey   string `yaml:"refresh_token_secret_key"`
+		AccessTokenSecretKey    string `env:"JWT_ACCESS_SECRET"`
+		RefreshTokenSecretKey   string `env:"JWT_REFRESH_SECRET"`
+		Issuer                  string `yaml:"issuer" env-default:"WBL0"`
+		// ClientSecrets maps client IDs to their secrets, as "publisher:<secret>,admin:<secret>"
+		ClientSecrets map[string]string `env:"JWT_CLIENT_SECRETS"`
+		Clients       []struct {
+			ID    string   `yaml:"id"`
+			Roles []string `yaml:"roles"`
+		} `yaml:"clients"`
 	} `yaml:"jwt"`
 }
 
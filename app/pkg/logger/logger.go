@@ -1,20 +1,41 @@
 package logger
 
 import (
+	"WBL0/app/pkg/config"
+	"context"
 	"fmt"
 	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
 	"io"
 	"os"
 	"path"
+	"path/filepath"
 	"runtime"
+	"strings"
+	"sync/atomic"
 )
 
+const (
+	OutputStdout = "stdout"
+	OutputFile   = "file"
+
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// writerHook writes every entry its package's level lets through to all writers.
+// The logger level itself is the most verbose of the base level and the overrides.
 type writerHook struct {
 	Writer    []io.Writer
 	LogLevels []logrus.Level
+	base      logrus.Level
+	packages  map[string]logrus.Level
 }
 
 func (hook *writerHook) Fire(entry *logrus.Entry) error {
+	if entry.Level > hook.levelFor(entry) {
+		return nil
+	}
 	line, err := entry.String()
 	if err != nil {
 		return err
@@ -29,7 +50,33 @@ func (hook *writerHook) Levels() []logrus.Level {
 	return hook.LogLevels
 }
 
-var e *logrus.Entry
+func (hook *writerHook) levelFor(entry *logrus.Entry) logrus.Level {
+	if len(hook.packages) == 0 || entry.Caller == nil {
+		return hook.base
+	}
+	pkg := packageOf(entry.Caller.Function)
+	for name, level := range hook.packages {
+		if pkg == name || strings.HasSuffix(pkg, "/"+name) {
+			return level
+		}
+	}
+	return hook.base
+}
+
+// packageOf turns "WBL0/app/internal/order.(*Handler).GetOrderById" into "WBL0/app/internal/order".
+func packageOf(function string) string {
+	slash := strings.LastIndex(function, "/")
+	if dot := strings.Index(function[slash+1:], "."); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
+
+// l is usable before Init, logging at info level to stdout; Init reconfigures it in place,
+// so loggers handed out earlier follow the configuration too.
+var l = newLogger()
+
+var e = logrus.NewEntry(l)
 
 type Logger struct {
 	*logrus.Entry
@@ -43,36 +90,130 @@ func (l *Logger) GetLoggerWithField(k string, v interface{}) *Logger {
 	return &Logger{l.WithField(k, v)}
 }
 
-func init() {
+// Sampler lets one of every n calls through, for lines that would otherwise be logged on every message.
+type Sampler struct {
+	every uint64
+	calls atomic.Uint64
+}
+
+func NewSampler(every int) *Sampler {
+	if every < 1 {
+		every = 1
+	}
+	return &Sampler{every: uint64(every)}
+}
+
+func (s *Sampler) Sample() bool {
+	return (s.calls.Add(1)-1)%s.every == 0
+}
+
+type loggerKey struct{}
+
+// ContextWithLogger stores a per-request logger, e.g. one carrying the request ID.
+func ContextWithLogger(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger stored in ctx, or fallback when there is none.
+func FromContext(ctx context.Context, fallback Logger) Logger {
+	if l, ok := ctx.Value(loggerKey{}).(Logger); ok {
+		return l
+	}
+	return fallback
+}
+
+func newLogger() *logrus.Logger {
 	l := logrus.New()
 	l.SetReportCaller(true)
-	l.Formatter = &logrus.TextFormatter{
-		CallerPrettyfier: func(frame *runtime.Frame) (function string, file string) {
-			filename := path.Base(frame.File)
-			return fmt.Sprintf("%s()", frame.Function), fmt.Sprintf("%s:%d", filename, frame.Line)
-		},
-		DisableColors: false,
-		FullTimestamp: true,
+	l.Formatter = textFormatter()
+	l.SetOutput(io.Discard)
+	l.AddHook(&writerHook{
+		Writer:    []io.Writer{os.Stdout},
+		LogLevels: logrus.AllLevels,
+		base:      logrus.InfoLevel,
+	})
+	l.SetLevel(logrus.InfoLevel)
+	return l
+}
+
+// Init configures the logger from the log section of cfg. It is called once from main.
+func Init(cfg config.Config) error {
+	writers := make([]io.Writer, 0, len(cfg.Log.Outputs))
+	for _, output := range cfg.Log.Outputs {
+		switch output {
+		case OutputStdout:
+			writers = append(writers, os.Stdout)
+		case OutputFile:
+			if err := os.MkdirAll(filepath.Dir(cfg.Log.File), 0755); err != nil {
+				return fmt.Errorf("cannot create log directory: %v", err)
+			}
+			writers = append(writers, &lumberjack.Logger{
+				Filename:   cfg.Log.File,
+				MaxSize:    cfg.Log.MaxSize,
+				MaxBackups: cfg.Log.MaxBackups,
+				MaxAge:     cfg.Log.MaxAge,
+				Compress:   cfg.Log.Compress,
+			})
+		default:
+			return fmt.Errorf("unknown log output %q", output)
+		}
 	}
+	return configure(cfg, writers...)
+}
+
+// InitWithWriter configures the logger like Init, but writes only to w, so tests can read what is logged.
+func InitWithWriter(cfg config.Config, w io.Writer) error {
+	return configure(cfg, w)
+}
 
-	err := os.MkdirAll("logs", 0644)
+func configure(cfg config.Config, writers ...io.Writer) error {
+	base, err := logrus.ParseLevel(cfg.Log.Level)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("invalid log level: %v", err)
 	}
 
-	allFile, err := os.OpenFile("logs/all.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
-	if err != nil {
-		panic(err)
+	hook := &writerHook{
+		Writer:    writers,
+		LogLevels: logrus.AllLevels,
+		base:      base,
+		packages:  make(map[string]logrus.Level, len(cfg.Log.Packages)),
+	}
+	maxLevel := base
+	for pkg, name := range cfg.Log.Packages {
+		level, err := logrus.ParseLevel(name)
+		if err != nil {
+			return fmt.Errorf("invalid log level for package %s: %v", pkg, err)
+		}
+		hook.packages[pkg] = level
+		if level > maxLevel {
+			maxLevel = level
+		}
 	}
 
-	l.SetOutput(io.Discard)
+	switch cfg.Log.Format {
+	case FormatText, "":
+		l.SetFormatter(textFormatter())
+	case FormatJSON:
+		l.SetFormatter(&logrus.JSONFormatter{CallerPrettyfier: callerPrettyfier})
+	default:
+		return fmt.Errorf("unknown log format %q", cfg.Log.Format)
+	}
 
-	l.AddHook(&writerHook{
-		Writer:    []io.Writer{allFile, os.Stdout},
-		LogLevels: logrus.AllLevels,
-	})
+	l.ReplaceHooks(logrus.LevelHooks{})
+	l.AddHook(hook)
+	l.SetLevel(maxLevel)
+	return nil
+}
 
-	l.SetLevel(logrus.TraceLevel)
+func textFormatter() *logrus.TextFormatter {
+	return &logrus.TextFormatter{
+		CallerPrettyfier: callerPrettyfier,
+		DisableColors:    false,
+		FullTimestamp:    true,
+	}
+}
 
-	e = logrus.NewEntry(l)
+func callerPrettyfier(frame *runtime.Frame) (function string, file string) {
+	filename := path.Base(frame.File)
+	return fmt.Sprintf("%s()", frame.Function), fmt.Sprintf("%s:%d", filename, frame.Line)
 }
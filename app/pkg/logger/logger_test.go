@@ -0,0 +1,104 @@
+package logger
+
+import (
+	"WBL0/app/pkg/config"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func testConfig(level, format string, packages map[string]string) config.Config {
+	var cfg config.Config
+	cfg.Log.Level = level
+	cfg.Log.Format = format
+	cfg.Log.Packages = packages
+	return cfg
+}
+
+func TestInitWithWriterFiltersByLevel(t *testing.T) {
+	var buf bytes.Buffer
+	if err := InitWithWriter(testConfig("warn", FormatText, nil), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	log := GetLogger()
+	log.Info("dropped")
+	log.Warn("kept")
+
+	if out := buf.String(); strings.Contains(out, "dropped") || !strings.Contains(out, "kept") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestInitWithWriterJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := InitWithWriter(testConfig("info", FormatJSON, nil), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	log := GetLogger()
+	log.GetLoggerWithField("request_id", "abc").Info("HANDLER: TEST")
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("expected a JSON line, got %q: %v", buf.String(), err)
+	}
+	if line["msg"] != "HANDLER: TEST" || line["request_id"] != "abc" {
+		t.Fatalf("unexpected fields: %v", line)
+	}
+}
+
+func TestPackageLevelOverride(t *testing.T) {
+	var buf bytes.Buffer
+	if err := InitWithWriter(testConfig("warn", FormatText, map[string]string{"logger": "debug"}), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	GetLogger().Debug("kept by override")
+	if !strings.Contains(buf.String(), "kept by override") {
+		t.Fatalf("expected the package override to let debug through, got %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := InitWithWriter(testConfig("warn", FormatText, map[string]string{"order": "debug"}), &buf); err != nil {
+		t.Fatal(err)
+	}
+	GetLogger().Debug("dropped")
+	if buf.Len() != 0 {
+		t.Fatalf("override of another package must not apply, got %q", buf.String())
+	}
+}
+
+func TestInitRejectsInvalidConfig(t *testing.T) {
+	if err := InitWithWriter(testConfig("loud", FormatText, nil), &bytes.Buffer{}); err == nil {
+		t.Fatal("expected an error for an unknown level")
+	}
+	if err := InitWithWriter(testConfig("info", "xml", nil), &bytes.Buffer{}); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+	if err := Init(func() config.Config {
+		cfg := testConfig("info", FormatText, nil)
+		cfg.Log.Outputs = []string{"syslog"}
+		return cfg
+	}()); err == nil {
+		t.Fatal("expected an error for an unknown output")
+	}
+}
+
+func TestSamplerKeepsOneInN(t *testing.T) {
+	s := NewSampler(3)
+	kept := 0
+	for i := 0; i < 9; i++ {
+		if s.Sample() {
+			kept++
+		}
+	}
+	if kept != 3 {
+		t.Fatalf("expected 3 of 9 calls sampled, got %d", kept)
+	}
+
+	if !NewSampler(0).Sample() {
+		t.Fatal("a sampler without a rate must keep every call")
+	}
+}
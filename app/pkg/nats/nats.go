@@ -1,16 +1,18 @@
 package nats
 
 import (
-	"WBL0/app/internal/delivery"
-	"WBL0/app/internal/item"
+	"WBL0/app/internal/deadletter"
+	"WBL0/app/internal/ingest"
+	"WBL0/app/internal/metrics"
 	"WBL0/app/internal/order"
-	"WBL0/app/internal/payment"
 	"WBL0/app/pkg/config"
 	"WBL0/app/pkg/logger"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/nats-io/nats.go"
+	"runtime/debug"
 	"time"
 )
 
@@ -32,100 +34,227 @@ func ConnectNATS(cfg config.Config) (*nats.Conn, error) {
 	return natsConn, nil
 }
 
-func SubNATS(cfg config.Config, log logger.Logger, natsConn *nats.Conn, deliveryService delivery.Service, paymentService payment.Service, itemService item.Service, orderService order.Service) error {
+const (
+	ModeCore      = "core"
+	ModeJetStream = "jetstream"
+)
+
+func SubNATS(cfg config.Config, log logger.Logger, natsConn *nats.Conn, ingestService ingest.Service) error {
+	sampler := logger.NewSampler(cfg.Log.SampleEvery)
+	if cfg.NATS.Mode == ModeJetStream {
+		return subJetStream(cfg, log, natsConn, ingestService, sampler)
+	}
+
 	subject := cfg.NATS.SUB
 
 	_, err := natsConn.Subscribe(subject, func(msg *nats.Msg) {
-		data := msg.Data
+		if err := handleMessage(log, msg, ingestService, sampler); err != nil {
+			publishDeadLetter(cfg, log, natsConn, nil, msg, err, 1)
+		}
+	})
+
+	if err != nil {
+		return fmt.Errorf("cannot subscribe to NATS %v", err)
+	}
+	return nil
+}
 
-		var dataMap map[string]json.RawMessage
+// subJetStream consumes the subject through a durable consumer, so orders
+// published while the service is down are delivered once it is back.
+// A message is acked only after its transaction has been committed.
+// nats.max_deliver is enforced here rather than by the consumer: a message is only
+// terminated once its dead letter is stored, and redelivered until then.
+func subJetStream(cfg config.Config, log logger.Logger, natsConn *nats.Conn, ingestService ingest.Service, sampler *logger.Sampler) error {
+	js, err := JetStream(cfg, natsConn)
+	if err != nil {
+		return err
+	}
+	if err = unlimitDeliveries(js, cfg.NATS.Stream, cfg.NATS.NAM); err != nil {
+		return err
+	}
+	ackWait := time.Duration(cfg.NATS.AckWait) * time.Second
 
-		if err := json.Unmarshal(data, &dataMap); err != nil {
-			fmt.Println("Ошибка разбора JSON:", err)
+	_, err = js.Subscribe(cfg.NATS.SUB, func(msg *nats.Msg) {
+		if err := handleMessage(log, msg, ingestService, sampler); err != nil {
+			attempts := 1
+			if meta, metaErr := msg.Metadata(); metaErr == nil {
+				attempts = int(meta.NumDelivered)
+			}
+			// Undecodable or invalid payloads will never succeed, so they skip redelivery
+			lastAttempt := cfg.NATS.MaxDeliver > 0 && attempts >= cfg.NATS.MaxDeliver
+			if ingest.Terminal(ingest.Stage(err)) || lastAttempt {
+				// Without a stored dead letter the message is redelivered, even past max_deliver
+				if publishDeadLetter(cfg, log, natsConn, js, msg, err, attempts) != nil {
+					msg.NakWithDelay(ackWait)
+					return
+				}
+				msg.Term()
+				return
+			}
+			msg.Nak()
 			return
 		}
+		if err := msg.Ack(); err != nil {
+			log.Error("cannot ack NATS message:", err)
+		}
+	},
+		nats.BindStream(cfg.NATS.Stream),
+		nats.Durable(cfg.NATS.NAM),
+		nats.ManualAck(),
+		nats.AckExplicit(),
+		nats.DeliverAll(),
+		nats.MaxDeliver(-1),
+		nats.AckWait(ackWait),
+	)
+	if err != nil {
+		return fmt.Errorf("cannot subscribe to JetStream %v", err)
+	}
+	return nil
+}
 
-		var (
-			createDeliveryDTO delivery.CreateDeliveryDTO
-			createPaymentDTO  payment.CreatePaymentDTO
-			createItemDTO     []*item.CreateItemDTO
-			createOrderDTO    order.Order
-		)
+// JetStream returns a JetStream context, creating the orders and dead-letter streams when they are missing.
+func JetStream(cfg config.Config, natsConn *nats.Conn) (nats.JetStreamContext, error) {
+	js, err := natsConn.JetStream()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create JetStream context %v", err)
+	}
 
-		if err := json.Unmarshal(dataMap["delivery"], &createDeliveryDTO); err != nil {
-			fmt.Printf("Error decoding delivery JSON: %v\n", err)
-			return
-		}
+	if err = ensureStream(js, cfg.NATS.Stream, cfg.NATS.SUB, 0); err != nil {
+		return nil, err
+	}
+	if err = ensureStream(js, cfg.NATS.DeadLetterStream, cfg.NATS.DeadLetterSubject, int64(cfg.NATS.DeadLetterLimit)); err != nil {
+		return nil, err
+	}
+	return js, nil
+}
 
-		if err := json.Unmarshal(dataMap["payment"], &createPaymentDTO); err != nil {
-			fmt.Printf("Error decoding payment JSON: %v\n", err)
-			return
-		}
+// ensureStream creates stream on subject, keeping at most maxMsgs messages, or all of them for 0.
+func ensureStream(js nats.JetStreamContext, stream, subject string, maxMsgs int64) error {
+	_, err := js.StreamInfo(stream)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, nats.ErrStreamNotFound) {
+		return fmt.Errorf("cannot get JetStream stream info %v", err)
+	}
 
-		if err := json.Unmarshal(dataMap["items"], &createItemDTO); err != nil {
-			fmt.Printf("Error decoding item JSON: %v\n", err)
-			return
-		}
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name:     stream,
+		Subjects: []string{subject},
+		Storage:  nats.FileStorage,
+		MaxMsgs:  maxMsgs,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot create JetStream stream %v", err)
+	}
+	return nil
+}
 
-		if err := json.Unmarshal(data, &createOrderDTO); err != nil {
-			fmt.Printf("Error decoding order JSON: %v\n", err)
-			return
-		}
+// unlimitDeliveries lifts the delivery limit of a durable consumer created with one,
+// so that it does not drop messages whose dead letter could not be stored.
+func unlimitDeliveries(js nats.JetStreamContext, stream, durable string) error {
+	info, err := js.ConsumerInfo(stream, durable)
+	if errors.Is(err, nats.ErrConsumerNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot get JetStream consumer info %v", err)
+	}
+	if info.Config.MaxDeliver == -1 {
+		return nil
+	}
 
-		delivery, err := deliveryService.Create(context.Background(), &createDeliveryDTO)
-		if err != nil {
-			fmt.Printf("Error creating delivery: %v\n", err)
-			return
-		}
-		log.Info("Input Delivery ID: ", delivery.ID)
+	consumerCfg := info.Config
+	consumerCfg.MaxDeliver = -1
+	if _, err = js.UpdateConsumer(stream, &consumerCfg); err != nil {
+		return fmt.Errorf("cannot update JetStream consumer %v", err)
+	}
+	return nil
+}
 
-		payment, err := paymentService.Create(context.Background(), &createPaymentDTO)
-		if err != nil {
-			fmt.Printf("Error creating payment: %v\n", err)
-			return
-		}
-		log.Info("Input Payment ID: ", payment.ID)
+func handleMessage(log logger.Logger, msg *nats.Msg, ingestService ingest.Service, sampler *logger.Sampler) (err error) {
+	data := msg.Data
 
-		itemIDs := []int64{}
-		items, err := itemService.Create(context.Background(), createItemDTO)
+	metrics.MessageReceived()
+	start := time.Now()
+	defer func() {
 		if err != nil {
-			fmt.Printf("Error creating item: %v\n", err)
+			metrics.MessageFailed(ingest.Stage(err), time.Since(start).Seconds())
 			return
 		}
-		for _, item := range items {
-			itemIDs = append(itemIDs, item.ID)
-			log.Info("Input Items ID: ", item.ID)
+		metrics.MessageSucceeded(time.Since(start).Seconds())
+	}()
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Errorf("panic while handling NATS message: %v\n%s", rec, debug.Stack())
+			err = &ingest.StageError{Stage: ingest.StagePanic, Err: fmt.Errorf("%v", rec)}
 		}
+	}()
 
-		crtord := order.CreateOrderDTO{
-			OrderUID:          createOrderDTO.OrderUID,
-			TrackNumber:       createOrderDTO.TrackNumber,
-			Entry:             createOrderDTO.Entry,
-			Delivery:          delivery.ID,
-			Payment:           payment.ID,
-			Items:             itemIDs,
-			Locale:            createOrderDTO.Locale,
-			InternalSignature: createOrderDTO.InternalSignature,
-			CustomerID:        createOrderDTO.CustomerID,
-			DeliveryService:   createOrderDTO.DeliveryService,
-			ShardKey:          createOrderDTO.ShardKey,
-			SMID:              createOrderDTO.SMID,
-			DateCreated:       createOrderDTO.DateCreated,
-			OofShard:          createOrderDTO.OofShard,
-		}
+	var input order.Order
+	if err = json.Unmarshal(data, &input); err != nil {
+		log.Warnf("cannot decode order message: %v", err)
+		return &ingest.StageError{Stage: ingest.StageDecode, Err: err}
+	}
 
-		order, err := orderService.Create(context.Background(), &crtord)
-		if err != nil {
-			fmt.Printf("Error creating order: %v\n", err)
-			return
-		}
-		log.Info("Input Order ID: ", order.OrderUID)
+	created, err := ingestService.Ingest(context.Background(), &input)
+	if err != nil {
+		log.Warnf("cannot ingest order message: %v", err)
+		return err
+	}
+	log.Info("Input Order ID: ", created.OrderUID)
 
-		fmt.Printf("\n\nReceived message: \n%s", string(data))
-	})
+	if sampler.Sample() {
+		log.Debugf("received message: %s", data)
+	}
+	return nil
+}
+
+const (
+	HeaderStage    = deadletter.HeaderStage
+	HeaderError    = deadletter.HeaderError
+	HeaderAttempts = deadletter.HeaderAttempts
+	HeaderSubject  = deadletter.HeaderSubject
+)
+
+// publishDeadLetter publishes msg to the dead-letter subject, through js when it is not nil,
+// so that the dead letter is reported as published only once the dead-letter stream stored it.
+func publishDeadLetter(cfg config.Config, log logger.Logger, natsConn *nats.Conn, js nats.JetStreamContext, msg *nats.Msg, err error, attempts int) error {
+	stage := ingest.Stage(err)
+
+	deadLetter := nats.NewMsg(cfg.NATS.DeadLetterSubject)
+	deadLetter.Data = msg.Data
+	deadLetter.Header = (&deadletter.Message{
+		Subject:  msg.Subject,
+		Stage:    stage,
+		Error:    err.Error(),
+		Attempts: attempts,
+	}).Header()
 
+	if js != nil {
+		_, err = js.PublishMsg(deadLetter, nats.AckWait(time.Duration(cfg.NATS.RequestTimeout)*time.Second))
+	} else {
+		err = natsConn.PublishMsg(deadLetter)
+	}
 	if err != nil {
-		return fmt.Errorf("cannot subscribe to NATS %v", err)
+		log.Error("cannot publish dead letter:", err)
+		return err
+	}
+	log.Warn("order message has been dead-lettered at stage ", stage)
+	return nil
+}
+
+// SubDeadLetter collects dead-lettered messages so they can be listed and re-driven. It is used
+// in core mode only: in JetStream mode the dead-letter stream stores them, see deadletter.StreamStorage.
+func SubDeadLetter(cfg config.Config, log logger.Logger, natsConn *nats.Conn, deadLetterService deadletter.Service) error {
+	_, err := natsConn.Subscribe(cfg.NATS.DeadLetterSubject, func(msg *nats.Msg) {
+		message := deadletter.FromHeader(msg.Header, msg.Data, time.Now())
+		if _, err := deadLetterService.Add(context.Background(), message); err != nil {
+			log.Error("cannot store dead letter:", err)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("cannot subscribe to dead-letter subject %v", err)
 	}
 	return nil
 }
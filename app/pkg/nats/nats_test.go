@@ -0,0 +1,628 @@
+package nats
+
+import (
+	"WBL0/app/internal/apperror"
+	"WBL0/app/internal/deadletter"
+	"WBL0/app/internal/ingest"
+	"WBL0/app/internal/order"
+	"WBL0/app/pkg/config"
+	"WBL0/app/pkg/logger"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeIngest struct {
+	mu    sync.Mutex
+	calls []string
+	fail  func(calls int) error
+}
+
+func (f *fakeIngest) Ingest(ctx context.Context, input *order.Order) (*order.CreateOrderDTO, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, input.OrderUID)
+	if f.fail != nil {
+		if err := f.fail(len(f.calls)); err != nil {
+			return nil, err
+		}
+	}
+	return &order.CreateOrderDTO{OrderUID: input.OrderUID}, nil
+}
+
+func (f *fakeIngest) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.calls)
+}
+
+func runServer(t *testing.T) *server.Server {
+	t.Helper()
+
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatalf("cannot create embedded NATS server: %v", err)
+	}
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("embedded NATS server is not ready")
+	}
+	t.Cleanup(srv.Shutdown)
+	return srv
+}
+
+func testConfig(url string) config.Config {
+	var cfg config.Config
+	cfg.NATS.URL = url
+	cfg.NATS.SUB = "orders"
+	cfg.NATS.NAM = "orders-test"
+	cfg.NATS.ConnectionTimeout = 5
+	cfg.NATS.Mode = ModeJetStream
+	cfg.NATS.Stream = "ORDERS"
+	cfg.NATS.MaxDeliver = 3
+	cfg.NATS.AckWait = 1
+	cfg.NATS.DeadLetterSubject = "orders.dlq"
+	cfg.NATS.DeadLetterStream = "ORDERS_DLQ"
+	cfg.NATS.RequestTimeout = 5
+	return cfg
+}
+
+func connect(t *testing.T, cfg config.Config) *nats.Conn {
+	t.Helper()
+
+	conn, err := ConnectNATS(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+	return conn
+}
+
+func publish(t *testing.T, conn *nats.Conn, subject, uid string) {
+	t.Helper()
+
+	data, err := json.Marshal(order.Order{OrderUID: uid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = js.Publish(subject, data); err != nil {
+		t.Fatalf("cannot publish: %v", err)
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func consumerInfo(t *testing.T, conn *nats.Conn, cfg config.Config) *nats.ConsumerInfo {
+	t.Helper()
+
+	js, err := conn.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := js.ConsumerInfo(cfg.NATS.Stream, cfg.NATS.NAM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func TestJetStreamAcksAfterIngest(t *testing.T) {
+	srv := runServer(t)
+	cfg := testConfig(srv.ClientURL())
+	conn := connect(t, cfg)
+
+	ingestService := &fakeIngest{}
+	if err := SubNATS(cfg, logger.GetLogger(), conn, ingestService); err != nil {
+		t.Fatal(err)
+	}
+
+	publish(t, conn, cfg.NATS.SUB, "b563feb7b2b84b6test")
+
+	waitFor(t, "ack", func() bool {
+		info := consumerInfo(t, conn, cfg)
+		return info.AckFloor.Consumer == 1 && info.NumAckPending == 0
+	})
+	if ingestService.count() != 1 {
+		t.Fatalf("expected one ingest call, got %d", ingestService.count())
+	}
+}
+
+func TestJetStreamRedeliversUntilMaxDeliver(t *testing.T) {
+	srv := runServer(t)
+	cfg := testConfig(srv.ClientURL())
+	conn := connect(t, cfg)
+
+	ingestService := &fakeIngest{fail: func(int) error { return errors.New("db is down") }}
+	if err := SubNATS(cfg, logger.GetLogger(), conn, ingestService); err != nil {
+		t.Fatal(err)
+	}
+
+	publish(t, conn, cfg.NATS.SUB, "b563feb7b2b84b6test")
+
+	waitFor(t, "redeliveries", func() bool { return ingestService.count() == cfg.NATS.MaxDeliver })
+	time.Sleep(2 * time.Duration(cfg.NATS.AckWait) * time.Second)
+	if ingestService.count() != cfg.NATS.MaxDeliver {
+		t.Fatalf("expected %d deliveries, got %d", cfg.NATS.MaxDeliver, ingestService.count())
+	}
+}
+
+func TestJetStreamRetriesFailedCommit(t *testing.T) {
+	srv := runServer(t)
+	cfg := testConfig(srv.ClientURL())
+	conn := connect(t, cfg)
+
+	ingestService := &fakeIngest{fail: func(calls int) error {
+		if calls == 1 {
+			return errors.New("serialization failure")
+		}
+		return nil
+	}}
+	if err := SubNATS(cfg, logger.GetLogger(), conn, ingestService); err != nil {
+		t.Fatal(err)
+	}
+
+	publish(t, conn, cfg.NATS.SUB, "b563feb7b2b84b6test")
+
+	waitFor(t, "ack after retry", func() bool {
+		info := consumerInfo(t, conn, cfg)
+		return info.AckFloor.Stream == 1 && info.NumAckPending == 0
+	})
+	if ingestService.count() != 2 {
+		t.Fatalf("expected two ingest calls, got %d", ingestService.count())
+	}
+}
+
+func TestJetStreamTerminatesUndecodableMessage(t *testing.T) {
+	srv := runServer(t)
+	cfg := testConfig(srv.ClientURL())
+	conn := connect(t, cfg)
+
+	ingestService := &fakeIngest{}
+	if err := SubNATS(cfg, logger.GetLogger(), conn, ingestService); err != nil {
+		t.Fatal(err)
+	}
+
+	js, err := conn.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = js.Publish(cfg.NATS.SUB, []byte("{not json")); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "termination", func() bool {
+		return consumerInfo(t, conn, cfg).NumAckPending == 0
+	})
+	time.Sleep(2 * time.Duration(cfg.NATS.AckWait) * time.Second)
+	if info := consumerInfo(t, conn, cfg); info.Delivered.Consumer != 1 {
+		t.Fatalf("expected a single delivery, got %d", info.Delivered.Consumer)
+	}
+	if ingestService.count() != 0 {
+		t.Fatalf("expected no ingest calls, got %d", ingestService.count())
+	}
+}
+
+func TestJetStreamDeliversOrdersPublishedWhileDown(t *testing.T) {
+	srv := runServer(t)
+	cfg := testConfig(srv.ClientURL())
+
+	first := connect(t, cfg)
+	if err := SubNATS(cfg, logger.GetLogger(), first, &fakeIngest{}); err != nil {
+		t.Fatal(err)
+	}
+	first.Close()
+
+	publisher := connect(t, cfg)
+	publish(t, publisher, cfg.NATS.SUB, "published-while-down")
+
+	ingestService := &fakeIngest{}
+	second := connect(t, cfg)
+	if err := SubNATS(cfg, logger.GetLogger(), second, ingestService); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "delivery after restart", func() bool { return ingestService.count() == 1 })
+	ingestService.mu.Lock()
+	defer ingestService.mu.Unlock()
+	if ingestService.calls[0] != "published-while-down" {
+		t.Fatalf("unexpected order delivered: %s", ingestService.calls[0])
+	}
+}
+
+func subscribeDeadLetters(t *testing.T, conn *nats.Conn, cfg config.Config) *nats.Subscription {
+	t.Helper()
+
+	sub, err := conn.SubscribeSync(cfg.NATS.DeadLetterSubject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return sub
+}
+
+func TestCoreDeadLettersFailedIngest(t *testing.T) {
+	srv := runServer(t)
+	cfg := testConfig(srv.ClientURL())
+	cfg.NATS.Mode = ModeCore
+	conn := connect(t, cfg)
+
+	ingestService := &fakeIngest{fail: func(int) error {
+		return &ingest.StageError{Stage: ingest.StagePayment, Err: errors.New("payment insert failed")}
+	}}
+	if err := SubNATS(cfg, logger.GetLogger(), conn, ingestService); err != nil {
+		t.Fatal(err)
+	}
+	dlq := subscribeDeadLetters(t, conn, cfg)
+
+	data, _ := json.Marshal(order.Order{OrderUID: "b563feb7b2b84b6test"})
+	if err := conn.Publish(cfg.NATS.SUB, data); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := dlq.NextMsg(5 * time.Second)
+	if err != nil {
+		t.Fatalf("no dead letter received: %v", err)
+	}
+	if got := msg.Header.Get(HeaderStage); got != ingest.StagePayment {
+		t.Fatalf("expected stage %q, got %q", ingest.StagePayment, got)
+	}
+	if got := msg.Header.Get(HeaderAttempts); got != "1" {
+		t.Fatalf("expected 1 attempt, got %q", got)
+	}
+	if got := msg.Header.Get(HeaderError); got == "" {
+		t.Fatal("expected error header")
+	}
+	if string(msg.Data) != string(data) {
+		t.Fatalf("dead letter payload differs from original: %s", msg.Data)
+	}
+}
+
+func TestJetStreamDeadLettersAfterMaxDeliver(t *testing.T) {
+	srv := runServer(t)
+	cfg := testConfig(srv.ClientURL())
+	conn := connect(t, cfg)
+
+	ingestService := &fakeIngest{fail: func(int) error {
+		return &ingest.StageError{Stage: ingest.StageItems, Err: errors.New("item insert failed")}
+	}}
+	if err := SubNATS(cfg, logger.GetLogger(), conn, ingestService); err != nil {
+		t.Fatal(err)
+	}
+	dlq := subscribeDeadLetters(t, conn, cfg)
+
+	publish(t, conn, cfg.NATS.SUB, "b563feb7b2b84b6test")
+
+	msg, err := dlq.NextMsg(5 * time.Second)
+	if err != nil {
+		t.Fatalf("no dead letter received: %v", err)
+	}
+	if got := msg.Header.Get(HeaderStage); got != ingest.StageItems {
+		t.Fatalf("expected stage %q, got %q", ingest.StageItems, got)
+	}
+	if got := msg.Header.Get(HeaderAttempts); got != "3" {
+		t.Fatalf("expected 3 attempts, got %q", got)
+	}
+	if ingestService.count() != cfg.NATS.MaxDeliver {
+		t.Fatalf("expected %d ingest calls, got %d", cfg.NATS.MaxDeliver, ingestService.count())
+	}
+}
+
+func TestJetStreamRedeliversUntilDeadLetterStored(t *testing.T) {
+	srv := runServer(t)
+	cfg := testConfig(srv.ClientURL())
+	conn := connect(t, cfg)
+
+	ingestService := &fakeIngest{fail: func(int) error {
+		return &ingest.StageError{Stage: ingest.StageItems, Err: errors.New("item insert failed")}
+	}}
+	if err := SubNATS(cfg, logger.GetLogger(), conn, ingestService); err != nil {
+		t.Fatal(err)
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without its stream the dead letter cannot be stored on the last attempt
+	if err = js.DeleteStream(cfg.NATS.DeadLetterStream); err != nil {
+		t.Fatal(err)
+	}
+
+	publish(t, conn, cfg.NATS.SUB, "b563feb7b2b84b6test")
+	waitFor(t, "delivery past max_deliver", func() bool { return ingestService.count() > cfg.NATS.MaxDeliver })
+	if info := consumerInfo(t, conn, cfg); info.NumAckPending+info.NumRedelivered == 0 {
+		t.Fatalf("expected the message to be kept for redelivery, got %+v", info)
+	}
+
+	if err = ensureStream(js, cfg.NATS.DeadLetterStream, cfg.NATS.DeadLetterSubject, 0); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "dead letter to be settled", func() bool {
+		info := consumerInfo(t, conn, cfg)
+		return info.NumAckPending == 0 && info.NumPending == 0
+	})
+	storage := deadletter.NewStreamStorage(js, cfg.NATS.DeadLetterStream, cfg.NATS.DeadLetterSubject, cfg.NATS.RequestTimeout)
+	messages, err := storage.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].Stage != ingest.StageItems || messages[0].Attempts <= cfg.NATS.MaxDeliver {
+		t.Fatalf("expected one dead letter stored after more than %d attempts, got %+v", cfg.NATS.MaxDeliver, messages)
+	}
+}
+
+func TestJetStreamLiftsConsumerDeliveryLimit(t *testing.T) {
+	srv := runServer(t)
+	cfg := testConfig(srv.ClientURL())
+	conn := connect(t, cfg)
+
+	js, err := JetStream(cfg, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A durable consumer left by a version that had the server enforce max_deliver
+	_, err = js.AddConsumer(cfg.NATS.Stream, &nats.ConsumerConfig{
+		Durable:        cfg.NATS.NAM,
+		DeliverSubject: nats.NewInbox(),
+		AckPolicy:      nats.AckExplicitPolicy,
+		AckWait:        time.Duration(cfg.NATS.AckWait) * time.Second,
+		MaxDeliver:     cfg.NATS.MaxDeliver,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = SubNATS(cfg, logger.GetLogger(), conn, &fakeIngest{}); err != nil {
+		t.Fatal(err)
+	}
+	if info := consumerInfo(t, conn, cfg); info.Config.MaxDeliver != -1 {
+		t.Fatalf("expected the consumer delivery limit to be lifted, got %d", info.Config.MaxDeliver)
+	}
+}
+
+func TestDeadLetterRedrive(t *testing.T) {
+	srv := runServer(t)
+	cfg := testConfig(srv.ClientURL())
+	cfg.NATS.Mode = ModeCore
+	conn := connect(t, cfg)
+
+	deadLetterService := deadletter.NewService(cfg, deadletter.NewMemoryStorage(10), logger.GetLogger(), conn, nil)
+	if err := SubDeadLetter(cfg, logger.GetLogger(), conn, deadLetterService); err != nil {
+		t.Fatal(err)
+	}
+
+	ingestService := &fakeIngest{fail: func(calls int) error {
+		if calls == 1 {
+			return &ingest.StageError{Stage: ingest.StageOrder, Err: errors.New("order insert failed")}
+		}
+		return nil
+	}}
+	if err := SubNATS(cfg, logger.GetLogger(), conn, ingestService); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := json.Marshal(order.Order{OrderUID: "b563feb7b2b84b6test"})
+	if err := conn.Publish(cfg.NATS.SUB, data); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "dead letter", func() bool { return len(listDeadLetters(t, deadLetterService)) == 1 })
+	message := listDeadLetters(t, deadLetterService)[0]
+	if message.Stage != ingest.StageOrder || message.Subject != cfg.NATS.SUB || message.Attempts != 1 {
+		t.Fatalf("unexpected dead letter: %+v", message)
+	}
+
+	if _, err := deadLetterService.Redrive(context.Background(), message.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "redriven message", func() bool { return ingestService.count() == 2 })
+	if n := len(listDeadLetters(t, deadLetterService)); n != 0 {
+		t.Fatalf("expected re-driven dead letter to be removed, %d left", n)
+	}
+}
+
+func listDeadLetters(t *testing.T, deadLetterService deadletter.Service) []*deadletter.Message {
+	t.Helper()
+
+	messages, err := deadLetterService.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return messages
+}
+
+func TestJetStreamDeadLetterRedrive(t *testing.T) {
+	srv := runServer(t)
+	cfg := testConfig(srv.ClientURL())
+	conn := connect(t, cfg)
+
+	ingestService := &fakeIngest{fail: func(calls int) error {
+		if calls == 1 {
+			return &ingest.StageError{Stage: ingest.StageValidate, Err: errors.New("missing delivery")}
+		}
+		return nil
+	}}
+	if err := SubNATS(cfg, logger.GetLogger(), conn, ingestService); err != nil {
+		t.Fatal(err)
+	}
+	publish(t, conn, cfg.NATS.SUB, "b563feb7b2b84b6test")
+	waitFor(t, "dead letter to be settled", func() bool {
+		info := consumerInfo(t, conn, cfg)
+		return info.NumAckPending == 0 && info.NumPending == 0
+	})
+
+	// The dead letter is kept in the stream, so it is found through a connection opened after it was stored
+	other := connect(t, cfg)
+	js, err := JetStream(cfg, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage := deadletter.NewStreamStorage(js, cfg.NATS.DeadLetterStream, cfg.NATS.DeadLetterSubject, cfg.NATS.RequestTimeout)
+	deadLetterService := deadletter.NewService(cfg, storage, logger.GetLogger(), other, js)
+
+	messages := listDeadLetters(t, deadLetterService)
+	if len(messages) != 1 {
+		t.Fatalf("expected one dead letter, got %d", len(messages))
+	}
+	message := messages[0]
+	if message.Stage != ingest.StageValidate || message.Subject != cfg.NATS.SUB || message.Attempts != 1 {
+		t.Fatalf("unexpected dead letter: %+v", message)
+	}
+
+	if _, err = deadLetterService.Redrive(context.Background(), message.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "redriven message", func() bool { return ingestService.count() == 2 })
+	if n := len(listDeadLetters(t, deadLetterService)); n != 0 {
+		t.Fatalf("expected re-driven dead letter to be removed, %d left", n)
+	}
+	if _, err = deadLetterService.Redrive(context.Background(), message.ID); !errors.Is(err, apperror.ErrEmptyString) {
+		t.Fatalf("expected a re-driven dead letter to be gone, got %v", err)
+	}
+}
+
+func TestDeadLetterRedriveToRecordedSubject(t *testing.T) {
+	srv := runServer(t)
+	cfg := testConfig(srv.ClientURL())
+	conn := connect(t, cfg)
+
+	js, err := JetStream(cfg, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = js.AddStream(&nats.StreamConfig{Name: "LEGACY", Subjects: []string{"orders.legacy"}}); err != nil {
+		t.Fatal(err)
+	}
+	storage := deadletter.NewStreamStorage(js, cfg.NATS.DeadLetterStream, cfg.NATS.DeadLetterSubject, cfg.NATS.RequestTimeout)
+	message, err := storage.Add(&deadletter.Message{Subject: "orders.legacy", Stage: ingest.StageOrder, Attempts: 3, Payload: "{}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadLetterService := deadletter.NewService(cfg, storage, logger.GetLogger(), conn, js)
+	if _, err = deadLetterService.Redrive(context.Background(), message.ID); err != nil {
+		t.Fatal(err)
+	}
+	info, err := js.StreamInfo("LEGACY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State.Msgs != 1 {
+		t.Fatalf("expected the dead letter to be re-driven to its recorded subject, got %d messages", info.State.Msgs)
+	}
+	if info, err = js.StreamInfo(cfg.NATS.Stream); err != nil || info.State.Msgs != 0 {
+		t.Fatalf("expected nothing re-driven to the orders subject: %v %+v", err, info)
+	}
+}
+
+func TestJetStreamSurvivesPanickingIngest(t *testing.T) {
+	srv := runServer(t)
+	cfg := testConfig(srv.ClientURL())
+	conn := connect(t, cfg)
+
+	ingestService := &fakeIngest{fail: func(calls int) error {
+		if calls == 1 {
+			panic("poisoned message")
+		}
+		return nil
+	}}
+	if err := SubNATS(cfg, logger.GetLogger(), conn, ingestService); err != nil {
+		t.Fatal(err)
+	}
+	dlq := subscribeDeadLetters(t, conn, cfg)
+
+	publish(t, conn, cfg.NATS.SUB, "poisoned")
+	msg, err := dlq.NextMsg(5 * time.Second)
+	if err != nil {
+		t.Fatalf("no dead letter received: %v", err)
+	}
+	if got := msg.Header.Get(HeaderStage); got != ingest.StagePanic {
+		t.Fatalf("expected stage %q, got %q", ingest.StagePanic, got)
+	}
+
+	publish(t, conn, cfg.NATS.SUB, "b563feb7b2b84b6test")
+	waitFor(t, "next message to be ingested", func() bool { return ingestService.count() == 2 })
+	waitFor(t, "both messages to be settled", func() bool {
+		info := consumerInfo(t, conn, cfg)
+		return info.NumAckPending == 0 && info.NumPending == 0
+	})
+}
+
+func validOrderJSON(uid string) []byte {
+	data, _ := json.Marshal(order.Order{
+		OrderUID: uid, TrackNumber: "WBILMTESTTRACK", Entry: "WBIL", Locale: "en",
+		CustomerID: "test", DeliveryService: "meest", DateCreated: "2021-11-26T06:22:19Z",
+		Delivery: order.Delivery{Name: "Test Testov", Phone: "+9720000000", City: "Kiryat Mozkin", Address: "Ploshad Mira 15"},
+		Payment:  order.Payment{Transaction: uid, Currency: "USD", Provider: "wbpay", Amount: 1817, PaymentDt: 1637907727},
+		Items:    []order.Item{{ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", RID: "ab4219087a764ae0btest", Name: "Mascaras", NmID: 2389212}},
+	})
+	return data
+}
+
+func TestCreateOrderWaitsForStreamAck(t *testing.T) {
+	srv := runServer(t)
+	cfg := testConfig(srv.ClientURL())
+	cfg.NATS.RequestTimeout = 1
+	conn := connect(t, cfg)
+
+	js, err := JetStream(cfg, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := order.NewHandler(cfg, logger.GetLogger(), conn, js, nil, nil).(*order.Handler)
+
+	w := httptest.NewRecorder()
+	h.CreateOrder(w, httptest.NewRequest(http.MethodPost, "/order", bytes.NewReader(validOrderJSON("b563feb7b2b84b6test"))))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	info, err := js.StreamInfo(cfg.NATS.Stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State.Msgs != 1 {
+		t.Fatalf("expected the order to be stored in the stream, got %d messages", info.State.Msgs)
+	}
+
+	// Without the stream nothing acks the order, so it must not be reported as accepted
+	if err = js.DeleteStream(cfg.NATS.Stream); err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	h.CreateOrder(w, httptest.NewRequest(http.MethodPost, "/order", bytes.NewReader(validOrderJSON("c563feb7b2b84b6test"))))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without a stream ack, got %d: %s", w.Code, w.Body)
+	}
+}
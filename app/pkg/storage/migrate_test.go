@@ -0,0 +1,176 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoadMigrationsEmbedded(t *testing.T) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 || migrations[0].Version != 1 {
+		t.Fatalf("expected migration 1 first, got %+v", migrations)
+	}
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version <= migrations[i-1].Version {
+			t.Fatalf("migrations are not ordered: %d after %d", migrations[i].Version, migrations[i-1].Version)
+		}
+	}
+}
+
+func TestLoadMigrationsOrdersAndPairs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0010_b.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"m/0010_b.down.sql": {Data: []byte("DROP TABLE b;")},
+		"m/0002_a.up.sql":   {Data: []byte("CREATE TABLE a ();")},
+		"m/0002_a.down.sql": {Data: []byte("DROP TABLE a;")},
+	}
+	migrations, err := loadMigrations(fsys, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Name != "a" || migrations[1].Version != 10 {
+		t.Fatalf("unexpected migrations: %+v", migrations)
+	}
+	if migrations[0].Down != "DROP TABLE a;" {
+		t.Fatalf("unexpected down migration: %q", migrations[0].Down)
+	}
+}
+
+func TestLoadMigrationsRejectsInvalidFiles(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"missing down": {"m/0001_a.up.sql": {Data: []byte("SELECT 1;")}},
+		"bad name":     {"m/init.sql": {Data: []byte("SELECT 1;")}},
+		"two names": {
+			"m/0001_a.up.sql":   {Data: []byte("SELECT 1;")},
+			"m/0001_b.down.sql": {Data: []byte("SELECT 1;")},
+		},
+	}
+	for name, fsys := range cases {
+		if _, err := loadMigrations(fsys, "m"); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// testPool connects to the database in TEST_DATABASE_DSN with a scratch schema, which is dropped
+// once the test is done. Tests that need Postgres are skipped without the variable.
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	ctx := context.Background()
+
+	admin, err := pgxpool.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(admin.Close)
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	if _, err = admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+	})
+
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.ConnectConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+// TestMigrateUpFromDbSql applies the migrations to the schema of the former db.sql, kept in
+// testdata, holding a duplicated order as the old ingestion could store.
+func TestMigrateUpFromDbSql(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	baseline, err := os.ReadFile("testdata/db.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pool.Exec(ctx, string(baseline)); err != nil {
+		t.Fatal(err)
+	}
+	_, err = pool.Exec(ctx, `
+		INSERT INTO Delivery (id, name) VALUES (1, 'first'), (2, 'second'), (3, 'other');
+		INSERT INTO Payment (id) VALUES (1), (2), (3);
+		INSERT INTO Item (id, track_number) VALUES (1, 'TRACK'), (2, 'TRACK');
+		INSERT INTO "order" (order_uid, delivery, payment, items) VALUES
+			('dup', 1, 1, '{1}'),
+			('dup', 2, 2, '{2}'),
+			('other', 3, 3, '{1,2}'),
+			(NULL, 3, 3, '{}');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := LoadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := MigrateUp(ctx, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations) {
+		t.Fatalf("expected %d migrations to be applied, got %d", len(migrations), len(applied))
+	}
+
+	var delivery int64
+	var orders int
+	if err = pool.QueryRow(ctx, `SELECT count(*) FROM "order"`).Scan(&orders); err != nil {
+		t.Fatal(err)
+	}
+	if err = pool.QueryRow(ctx, `SELECT delivery FROM "order" WHERE order_uid = 'dup'`).Scan(&delivery); err != nil {
+		t.Fatal(err)
+	}
+	if orders != 2 || delivery != 2 {
+		t.Fatalf("expected the last duplicate to be kept of 2 orders, got %d orders, delivery %d", orders, delivery)
+	}
+
+	var items []int64
+	err = pool.QueryRow(ctx, `SELECT array_agg(item_id ORDER BY position) FROM order_items WHERE order_uid = 'other'`).Scan(&items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0] != 1 || items[1] != 2 {
+		t.Fatalf("expected the item IDs to be moved to order_items, got %v", items)
+	}
+
+	if _, err = pool.Exec(ctx, `INSERT INTO "order" (order_uid, delivery, payment) VALUES ('dup', 3, 3)`); err == nil {
+		t.Fatal("expected order_uid to be the primary key")
+	}
+	if _, err = pool.Exec(ctx, `INSERT INTO order_flag (order_uid, violations) VALUES ('dup', '[]')`); err != nil {
+		t.Fatalf("expected order_flag to reference the order: %v", err)
+	}
+
+	// Applying again is a no-op, and every migration can be reverted and reapplied
+	if applied, err = MigrateUp(ctx, pool); err != nil || len(applied) != 0 {
+		t.Fatalf("expected nothing left to apply, got %d migrations, %v", len(applied), err)
+	}
+	if _, err = MigrateDown(ctx, pool, len(migrations)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = MigrateUp(ctx, pool); err != nil {
+		t.Fatal(err)
+	}
+}
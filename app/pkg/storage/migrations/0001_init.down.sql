@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS "order";
+DROP TABLE IF EXISTS Delivery;
+DROP TABLE IF EXISTS Payment;
+DROP TABLE IF EXISTS Item;
@@ -0,0 +1,77 @@
+CREATE TABLE IF NOT EXISTS Delivery (
+ id         serial primary key,
+ name       text,
+ phone      text,
+ zip        text,
+ city       text,
+ address    text,
+ region     text,
+ email      text
+);
+
+
+CREATE TABLE IF NOT EXISTS Payment (
+ id              serial primary key,
+ transaction     text,
+ request_id      text,
+ currency        text,
+ provider        text,
+ amount          int,
+ payment_dt      bigint,
+ bank            text,
+ delivery_cost   int,
+ goods_total     int,
+ custom_fee      int
+);
+
+CREATE TABLE IF NOT EXISTS Item (
+ id              serial primary key,
+ chrt_id         int,
+ track_number    text,
+ price           int,
+ rid             text,
+ name            text,
+ sale            int,
+ size            text,
+ total_price     int,
+ nm_id           int,
+ brand           text,
+ status          int
+);
+
+CREATE TABLE IF NOT EXISTS "order" (
+ order_uid          text primary key,
+ track_number       text,
+ entry              text,
+ delivery           bigint,
+ payment            bigint,
+ items              bigint[],
+ locale             text,
+ internal_signature text,
+ customer_id        text,
+ delivery_service   text,
+ shardkey           text,
+ sm_id              int,
+ date_created       text,
+ oof_shard          text,
+
+ foreign key(delivery) references Delivery(id) on delete cascade,
+ foreign key(payment) references Payment(id) on delete cascade
+);
+
+-- "order" created by the former db.sql has no primary key, which order_flag and order_items reference.
+-- Before adding it, rows without an order_uid are dropped and of duplicates the last ingested one,
+-- with the highest delivery ID, is kept.
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = '"order"'::regclass AND contype = 'p') THEN
+    DELETE FROM "order" WHERE order_uid IS NULL;
+    DELETE FROM "order" o
+    USING "order" newer
+    WHERE o.order_uid = newer.order_uid
+      AND (COALESCE(o.delivery, 0) < COALESCE(newer.delivery, 0)
+        OR COALESCE(o.delivery, 0) = COALESCE(newer.delivery, 0) AND o.ctid < newer.ctid);
+    ALTER TABLE "order" ADD PRIMARY KEY (order_uid);
+  END IF;
+END
+$$;
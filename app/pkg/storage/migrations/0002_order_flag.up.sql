@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS order_flag (
+ order_uid          text primary key,
+ violations         jsonb not null,
+ flagged_at         timestamptz not null default now(),
+
+ foreign key(order_uid) references "order"(order_uid) on delete cascade
+);
@@ -0,0 +1,7 @@
+ALTER TABLE "order" ADD COLUMN items bigint[];
+
+UPDATE "order" o
+SET items = COALESCE((SELECT array_agg(oi.item_id ORDER BY oi.position) FROM order_items oi WHERE oi.order_uid = o.order_uid), '{}');
+
+DROP TABLE IF EXISTS order_items;
+DROP INDEX IF EXISTS item_nm_id_idx;
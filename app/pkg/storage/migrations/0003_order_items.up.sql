@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS order_items (
+ order_uid          text not null,
+ item_id            bigint not null,
+ position           int not null,
+
+ primary key(order_uid, position),
+ foreign key(order_uid) references "order"(order_uid) on delete cascade,
+ foreign key(item_id) references Item(id) on delete cascade
+);
+
+CREATE INDEX IF NOT EXISTS order_items_item_id_idx ON order_items (item_id);
+CREATE INDEX IF NOT EXISTS item_nm_id_idx ON Item (nm_id);
+
+-- IDs of items deleted before the relation existed have nothing to reference and are dropped
+INSERT INTO order_items (order_uid, item_id, position)
+SELECT o.order_uid, u.id, u.position
+FROM "order" o, unnest(o.items) WITH ORDINALITY AS u(id, position)
+WHERE EXISTS (SELECT 1 FROM Item it WHERE it.id = u.id);
+
+ALTER TABLE "order" DROP COLUMN items;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS item_track_number_idx;
+DROP INDEX IF EXISTS order_track_number_idx;
+DROP INDEX IF EXISTS order_customer_id_idx;
@@ -0,0 +1,4 @@
+-- GET /customers/:id/orders pages by date_created with order_uid as a tie-breaker, both compared as COLLATE "C"
+CREATE INDEX IF NOT EXISTS order_customer_id_idx ON "order" (customer_id, date_created COLLATE "C", order_uid COLLATE "C");
+CREATE INDEX IF NOT EXISTS order_track_number_idx ON "order" (track_number);
+CREATE INDEX IF NOT EXISTS item_track_number_idx ON Item (track_number);
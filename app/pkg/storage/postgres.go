@@ -4,27 +4,32 @@ import (
 	"WBL0/app/pkg/config"
 	"context"
 	"fmt"
-	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"time"
 )
 
-func ConnectDB(cfg config.Config) (*pgx.Conn, error) {
+func ConnectDB(cfg config.Config) (*pgxpool.Pool, error) {
 
-	pgxConfig, err := pgx.ParseConfig(cfg.PostgreSQL.DSN)
+	poolConfig, err := pgxpool.ParseConfig(cfg.PostgreSQL.DSN)
 	if err != nil {
 		return nil, fmt.Errorf("cannot parse database config from dsn %v", err)
 	}
+	poolConfig.MaxConns = cfg.PostgreSQL.MaxConns
+	poolConfig.MinConns = cfg.PostgreSQL.MinConns
+	poolConfig.MaxConnIdleTime = time.Duration(cfg.PostgreSQL.MaxConnIdleTime) * time.Second
+	poolConfig.HealthCheckPeriod = time.Duration(cfg.PostgreSQL.HealthCheckPeriod) * time.Second
 
 	dbTimeout, dbCancel := context.WithTimeout(context.Background(), time.Duration(cfg.PostgreSQL.ConnectionTimeout)*time.Second)
 	defer dbCancel()
 
-	dbConn, err := pgx.ConnectConfig(dbTimeout, pgxConfig)
+	dbPool, err := pgxpool.ConnectConfig(dbTimeout, poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("cannot connect to database: %v", err)
 	}
 
-	if err = dbConn.Ping(dbTimeout); err != nil {
-		fmt.Errorf("cannot ping database: %v", err)
+	if err = dbPool.Ping(dbTimeout); err != nil {
+		dbPool.Close()
+		return nil, fmt.Errorf("cannot ping database: %v", err)
 	}
-	return dbConn, nil
+	return dbPool, nil
 }